@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/evris99/mumble-jackson/player"
+	"layeh.com/gumble/gumble"
+)
+
+const (
+	PolicyReconnect = "reconnect"
+	PolicyExit      = "exit"
+)
+
+var ErrPolicy = errors.New("the policy must be reconnect or exit")
+
+// The configuration for reconnecting after a disconnect
+type ReconnectConfig struct {
+	InitialDelaySeconds uint   `toml:"initial_delay_seconds"`
+	MaxDelaySeconds     uint   `toml:"max_delay_seconds"`
+	OnKick              string `toml:"on_kick"`
+	OnBan               string `toml:"on_ban"`
+}
+
+// Checks that the policies of the config are valid
+func (c *ReconnectConfig) validate() error {
+	for _, policy := range []string{c.OnKick, c.OnBan} {
+		if policy != PolicyReconnect && policy != PolicyExit {
+			return ErrPolicy
+		}
+	}
+
+	return nil
+}
+
+// Connects to the server and binds the player to the client. When the client
+// is disconnected the player is paused and the bot connects again,
+// unless the policy for the disconnect reason is to exit.
+// If resume is true the player starts after the first connection.
+// Returns the connected client when a signal is received, or nil if
+// not connected or if the bot exits because of the disconnect policy.
+func runConnection(address string, gumbleConf *gumble.Config, tlsConf *tls.Config, conf *ReconnectConfig, p *player.Player, resume bool, disconnects chan *gumble.DisconnectEvent, signals chan os.Signal) *gumble.Client {
+	for {
+		client := connect(address, gumbleConf, tlsConf, conf, signals)
+		if client == nil {
+			return nil
+		}
+
+		p.SetClient(client)
+		if resume {
+			if err := p.Start(); err != nil {
+				log.Println(err)
+			}
+		}
+
+		var e *gumble.DisconnectEvent
+		select {
+		case e = <-disconnects:
+		case sig := <-signals:
+			log.Printf("Received %v, shutting down\n", sig)
+			return client
+		}
+
+		reason, policy := getDisconnectPolicy(e, conf)
+		log.Printf("Disconnect reason is %s: %s\n", reason, e.String)
+		if policy == PolicyExit {
+			log.Println("Exiting after disconnect")
+			return nil
+		}
+
+		// The player keeps the current track and its position
+		// so that it continues after reconnecting.
+		resume = p.Pause() == nil
+	}
+}
+
+// Dials the server until it succeeds, waiting between the attempts with
+// exponential backoff and jitter. Returns the connected client
+// or nil if a signal is received while waiting.
+func connect(address string, gumbleConf *gumble.Config, tlsConf *tls.Config, conf *ReconnectConfig, signals chan os.Signal) *gumble.Client {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	delay := time.Duration(conf.InitialDelaySeconds) * time.Second
+	if delay < time.Second {
+		delay = time.Second
+	}
+
+	maxDelay := time.Duration(conf.MaxDelaySeconds) * time.Second
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+
+	for {
+		client, err := gumble.DialWithDialer(new(net.Dialer), address, gumbleConf, tlsConf)
+		if err == nil {
+			return client
+		}
+
+		// Wait a random time between half the delay and the delay
+		// so that many clients do not reconnect at the same time.
+		wait := delay/2 + time.Duration(rng.Int63n(int64(delay/2)+1))
+		log.Printf("Could not connect: %v. Retrying in %v\n", err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case sig := <-signals:
+			log.Printf("Received %v, shutting down\n", sig)
+			return nil
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// Saves the state of the player and stops it, says goodbye
+// and disconnects the client if it is connected
+func shutdown(c *gumble.Client, p *player.Player, goodbye string) {
+	if err := p.Close(); err != nil {
+		log.Println(err)
+	}
+
+	if c == nil {
+		return
+	}
+
+	if goodbye != "" {
+		c.Self.Channel.Send(goodbye, false)
+	}
+
+	if err := c.Disconnect(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns the reason of the disconnect and the policy for it
+func getDisconnectPolicy(e *gumble.DisconnectEvent, conf *ReconnectConfig) (string, string) {
+	switch e.Type {
+	case gumble.DisconnectBanned:
+		return "user banned", conf.OnBan
+	case gumble.DisconnectKicked:
+		return "user kicked", conf.OnKick
+	case gumble.DisconnectUser:
+		return "user disconnect", PolicyExit
+	default:
+		return "connection error", PolicyReconnect
+	}
+}
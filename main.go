@@ -5,15 +5,20 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"log"
-	"net"
 	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/evris99/mumble-music-bot/player"
-	"github.com/evris99/mumble-music-bot/youtube_search"
+	"github.com/evris99/mumble-jackson/player"
+	"github.com/evris99/mumble-jackson/youtube_search"
 	"layeh.com/gumble/gumble"
 	"layeh.com/gumble/gumbleutil"
 	_ "layeh.com/gumble/opus"
@@ -26,16 +31,50 @@ var (
 	ErrTooFewArgs      = errors.New("too few arguments in command")
 	ErrNoURLFound      = errors.New("no url source found")
 	ErrNoYoutubeAPIKey = errors.New("cannot search without API key")
+	ErrInvalidNumber   = errors.New("invalid number in command")
+	ErrInvalidTime     = errors.New("invalid time in command")
+	ErrShuffleMode     = errors.New("unknown shuffle mode")
+	ErrStationName     = errors.New("invalid station name")
 )
 
+// The number of skipped videos of a playlist that are shown with their reason
+const MaxSkippedShown = 5
+
+// How often the progress of a playlist import is reported
+const importProgressInterval = 10 * time.Second
+
 const helpmessage string = `<h2>Usage</h2><br>
 <b>%[1]sinfo | %[1]scurrent | %[1]scur</b>: Shows current song info.<br>
 <b>%[1]sstart | %[1]splay</b>: Starts the playlist.<br>
 <b>%[1]sstop</b>: Stops the playlist.<br>
-<b>%[1]sadd | %[1]surl $URL</b>: Add the youtube URL of a song or a playlist to the queue.<br>
-<b>%[1]ssearch $QUERY</b>: Searches and adds the song to the playlist.<br>
+<b>%[1]spause</b>: Pauses the current song.<br>
+<b>%[1]sresume</b>: Resumes the paused song from where it was paused.<br>
+<b>%[1]sadd | %[1]surl $URL</b>: Add the youtube URL of a song or a playlist, the URL of an audio file or the URL of a radio station to the queue.<br>
+<b>%[1]sadd --playlist $URL | %[1]sadd --playlist=video | all | from $URL</b>: Adds only the song, the whole playlist or the playlist starting from the song of a youtube URL that has both.<br>
+<b>%[1]ssearch $QUERY</b>: Searches youtube and shows the top results.<br>
+<b>%[1]spick $NUM</b>: Adds the search result at the given position to the playlist.<br>
+<b>%[1]ssearch! $QUERY</b>: Searches and adds the first result to the playlist.<br>
+<b>%[1]slocal $QUERY</b>: Searches the music library and adds the song to the playlist.<br>
+<b>%[1]salbum $NAME</b>: Adds the songs of the album from the music library to the playlist.<br>
+<b>%[1]srescan</b>: Scans the music library again for new songs.<br>
+<b>%[1]spodcast $URL</b>: Shows the recent episodes of the podcast feed.<br>
+<b>%[1]spodcast $URL $NUM</b>: Adds the episode at the given position of the podcast feed to the playlist.<br>
+<b>%[1]sradio</b>: Shows the saved radio stations.<br>
+<b>%[1]sradio $NAME</b>: Plays the saved radio station now.<br>
+<b>%[1]sradio add $NAME $URL</b>: Saves the radio station of the URL with the name.<br>
+<b>%[1]sradio remove $NAME</b>: Removes the saved radio station.<br>
+<b>%[1]sseek $TIME | %[1]sseek +$TIME | %[1]sseek -$TIME</b>: Moves the current song to the given time (e.g. 1:23) or forwards and backwards by the given time (e.g. +30s).<br>
 <b>%[1]sskip | %[1]snext</b>: Skips a track from the playlist.<br>
+<b>%[1]sprevious | %[1]sback</b>: Plays the previous song again.<br>
+<b>%[1]shistory</b>: Shows list of played songs.<br>
+<b>%[1]splaynext $URL</b>: Add the youtube URL of a song or a playlist to the start of the queue. It accepts the same --playlist flag as add.<br>
+<b>%[1]sremove $NUM | %[1]sremove $FROM-$TO</b>: Removes the song or the range of songs at the given positions of the queue.<br>
+<b>%[1]smove $FROM $TO</b>: Moves the song at position $FROM of the queue to position $TO.<br>
+<b>%[1]sswap $NUM1 $NUM2</b>: Swaps the songs at the given positions of the queue.<br>
 <b>%[1]sclear</b>: Clears the playlist.<br>
+<b>%[1]sshuffle</b>: Shuffles the playlist once.<br>
+<b>%[1]sshuffle on | off</b>: Turns on or off playing the songs of the playlist in random order.<br>
+<b>%[1]srepeat one | all | off</b>: Repeats the current song, repeats the whole playlist or stops repeating.<br>
 <b>%[1]svol | %[1]svolume $NUM</b>: Sets the volume to the specified number. The number must be between 0-100.<br>
 <b>%[1]shelp</b>: Shows this message.<br>
 <b>%[1]slist | %[1]squeue</b>: Shows list of next songs.<br>`
@@ -49,15 +88,28 @@ type CertConfig struct {
 
 // The global configuration
 type Config struct {
-	Address           string      `toml:"address"`
-	Port              uint16      `toml:"port"`
-	Username          string      `toml:"username"`
-	Password          string      `toml:"password"`
-	Prefix            string      `toml:"command_prefix"`
-	VerifyCertificate bool        `toml:"verify_server_certificate"`
-	CertConf          *CertConfig `toml:"certificate"`
-	YoutubeAPIKey     string      `toml:"youtube_api_key"`
-	DefaultVolume     uint8       `toml:"default_volume"`
+	Address           string              `toml:"address"`
+	Port              uint16              `toml:"port"`
+	Username          string              `toml:"username"`
+	Password          string              `toml:"password"`
+	Prefix            string              `toml:"command_prefix"`
+	VerifyCertificate bool                `toml:"verify_server_certificate"`
+	CertConf          *CertConfig         `toml:"certificate"`
+	YoutubeAPIKey     string              `toml:"youtube_api_key"`
+	DefaultVolume     uint8               `toml:"default_volume"`
+	PrefetchSeconds   uint                `toml:"prefetch_seconds"`
+	MaxFailures       int                 `toml:"max_consecutive_failures"`
+	Reconnect         *ReconnectConfig    `toml:"reconnect"`
+	GoodbyeMessage    string              `toml:"goodbye_message"`
+	StateFile         string              `toml:"state_file"`
+	ResumeOnStart     bool                `toml:"resume_on_start"`
+	LibraryPath       string              `toml:"library_path"`
+	RadioStations     map[string]string   `toml:"radio_stations"`
+	PlaylistMode      player.PlaylistMode `toml:"watch_playlist_mode"`
+	ImportWorkers     int                 `toml:"playlist_import_workers"`
+	MaxQueueSize      int                 `toml:"max_queue_size"`
+	SearchResults     int                 `toml:"search_results"`
+	SearchBackend     string              `toml:"search_backend"`
 }
 
 func main() {
@@ -70,10 +122,62 @@ func main() {
 	gumbleConf.Username = config.Username
 	gumbleConf.Password = config.Password
 
-	player := player.New(config.DefaultVolume)
+	library, err := loadLibrary(config.LibraryPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	player := player.New(player.Config{
+		DefaultVolume: config.DefaultVolume,
+		PrefetchTime:  time.Duration(config.PrefetchSeconds) * time.Second,
+		MaxFailures:   config.MaxFailures,
+		StateFile:     config.StateFile,
+		Stations:      config.RadioStations,
+		ImportWorkers: config.ImportWorkers,
+		MaxQueueSize:  config.MaxQueueSize,
+	})
+
+	// The library is set before the state is loaded
+	// so that its tracks can be restored
+	if library != nil {
+		player.SetLibrary(library)
+		go func() {
+			count, err := player.RescanLibrary()
+			if err != nil {
+				log.Printf("Could not scan the library: %v\n", err)
+				return
+			}
+			log.Printf("Found %d songs in the library\n", count)
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	// Resolving the saved tracks can take a while. A signal stops
+	// the bot meanwhile and the state file is kept as it is.
+	loaded := make(chan bool, 1)
+	go func() {
+		wasPlaying, err := player.LoadState()
+		if err != nil {
+			log.Printf("Could not load the state: %v\n", err)
+		}
+		loaded <- wasPlaying
+	}()
+
+	var wasPlaying bool
+	select {
+	case wasPlaying = <-loaded:
+	case sig := <-signals:
+		log.Printf("Received %v while loading the state, shutting down\n", sig)
+		return
+	}
+
+	// Buffered so that the disconnect on shutdown does not block
+	disconnects := make(chan *gumble.DisconnectEvent, 1)
 	gumbleConf.Attach(gumbleutil.Listener{
 		TextMessage: handleMessage(player, config),
-		Disconnect:  handleDisconnect,
+		Disconnect:  handleDisconnect(disconnects),
 	})
 
 	tlsConf, tlsErr := getTLSConfig(*config)
@@ -81,14 +185,14 @@ func main() {
 		log.Fatalln(tlsErr)
 	}
 
+	// Block until a signal is received
 	address := fmt.Sprintf("%s:%d", config.Address, config.Port)
-	_, err := gumble.DialWithDialer(new(net.Dialer), address, gumbleConf, tlsConf)
-	if err != nil {
-		log.Fatalln(err)
-	}
+	resume := config.ResumeOnStart && wasPlaying
+	client := runConnection(address, gumbleConf, tlsConf, config.Reconnect, player, resume, disconnects, signals)
 
-	// Block forever
-	select {}
+	// The bot also exits successfully after a disconnect with the exit
+	// policy, so that a service that restarts on failure is not restarted
+	shutdown(client, player, config.GoodbyeMessage)
 }
 
 // Loads the config from the path argument and returns the config
@@ -102,6 +206,19 @@ func loadConfig(path string) *Config {
 		VerifyCertificate: false,
 		CertConf:          new(CertConfig),
 		DefaultVolume:     60,
+		PrefetchSeconds:   15,
+		MaxFailures:       3,
+		ImportWorkers:     player.DefaultImportWorkers,
+		MaxQueueSize:      player.DefaultMaxQueueSize,
+		SearchResults:     5,
+		SearchBackend:     SearchAuto,
+		GoodbyeMessage:    "Goodbye!",
+		Reconnect: &ReconnectConfig{
+			InitialDelaySeconds: 1,
+			MaxDelaySeconds:     300,
+			OnKick:              PolicyReconnect,
+			OnBan:               PolicyExit,
+		},
 	}
 
 	_, err := toml.DecodeFile(path, conf)
@@ -113,9 +230,31 @@ func loadConfig(path string) *Config {
 		log.Fatalln("The volume must be between 0 and 100")
 	}
 
+	if conf.SearchResults < 1 || conf.SearchResults > 50 {
+		log.Fatalln("The number of search results must be between 1 and 50")
+	}
+
+	if _, err := getSearcher(conf); errors.Is(err, ErrSearchBackend) {
+		log.Fatalln(err)
+	}
+
+	if err := conf.Reconnect.validate(); err != nil {
+		log.Fatalln(err)
+	}
+
 	return conf
 }
 
+// Creates the music library of the path or
+// returns nil if the path is empty
+func loadLibrary(path string) (*player.Library, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	return player.NewLibrary(path)
+}
+
 // Receives the program's config and returns
 // the corresponding TLS config
 func getTLSConfig(c Config) (*tls.Config, error) {
@@ -143,6 +282,7 @@ func getTLSConfig(c Config) (*tls.Config, error) {
 
 // Returns a function to handle the text message event
 func handleMessage(player *player.Player, config *Config) func(e *gumble.TextMessageEvent) {
+	searches := newPendingSearches()
 	return func(e *gumble.TextMessageEvent) {
 
 		message := strings.TrimSpace(e.Message)
@@ -156,17 +296,73 @@ func handleMessage(player *player.Player, config *Config) func(e *gumble.TextMes
 
 		switch words[0] {
 		case "start", "play":
-			response, err = onStart(player, e.Client)
+			response, err = onStart(player)
 		case "add", "url":
-			response, err = onAdd(player, e.Client, words)
+			inBackground(e.Client, func() (string, error) {
+				return onAdd(player, words, config, e.Client)
+			})
+		case "playnext":
+			inBackground(e.Client, func() (string, error) {
+				return onPlayNext(player, words, config, e.Client)
+			})
+		case "remove":
+			response, err = onRemove(player, words)
+		case "move":
+			response, err = onMove(player, words)
+		case "swap":
+			response, err = onSwap(player, words)
 		case "search":
-			response, err = onSearch(player, e.Client, words, config)
+			user := senderName(e)
+			inBackground(e.Client, func() (string, error) {
+				return onSearch(words, config, searches, user)
+			})
+		case "search!":
+			inBackground(e.Client, func() (string, error) {
+				return onSearchFirst(player, words, config)
+			})
+		case "pick":
+			user := senderName(e)
+			inBackground(e.Client, func() (string, error) {
+				return onPick(player, words, searches, user)
+			})
+		case "local":
+			inBackground(e.Client, func() (string, error) {
+				return onLocal(player, words)
+			})
+		case "album":
+			inBackground(e.Client, func() (string, error) {
+				return onAlbum(player, words)
+			})
+		case "rescan":
+			response, err = onRescan(player, e.Client), nil
+		case "podcast":
+			inBackground(e.Client, func() (string, error) {
+				return onPodcast(player, words)
+			})
+		case "radio":
+			inBackground(e.Client, func() (string, error) {
+				return onRadio(player, words)
+			})
 		case "stop":
 			response, err = onStop(player)
+		case "pause":
+			response, err = onPause(player)
+		case "resume":
+			response, err = onResume(player)
+		case "seek":
+			response, err = onSeek(player, words)
 		case "skip", "next":
 			response, err = onSkip(player)
+		case "previous", "back":
+			response, err = onPrevious(player)
+		case "history":
+			response, err = onHistory(player)
 		case "vol", "volume":
 			response, err = onVolume(player, words)
+		case "shuffle":
+			response, err = onShuffle(player, words)
+		case "repeat":
+			response, err = onRepeat(player, words)
 		case "clear":
 			response, err = onClear(player), nil
 		case "info", "current", "cur":
@@ -178,28 +374,48 @@ func handleMessage(player *player.Player, config *Config) func(e *gumble.TextMes
 			response, err = fmt.Sprintf(helpmessage, config.Prefix), nil
 		}
 
-		if handleError(err, e.Client) {
+		if handleError(err, e.Client) && response != "" {
 			e.Client.Self.Channel.Send(response, false)
 		}
 	}
 }
 
-// Runs when the client is disconnected
-// It just logs and exits
-func handleDisconnect(e *gumble.DisconnectEvent) {
-	var reason string
-	switch e.Type {
-	case gumble.DisconnectError:
-		reason = "connection error"
-	case gumble.DisconnectBanned:
-		reason = "user banned"
-	case gumble.DisconnectKicked:
-		reason = "user kicked"
-	case gumble.DisconnectUser:
-		reason = "user disconnect"
+// Runs the command in the background and sends its answer or error when
+// it finishes, so that slow commands do not block the other commands
+func inBackground(c *gumble.Client, command func() (string, error)) {
+	go func() {
+		response, err := command()
+		if handleError(err, c) && response != "" {
+			c.Self.Channel.Send(response, false)
+		}
+	}()
+}
+
+// Returns a function that announces the size of a playlist when its
+// import starts and reports its progress at most once every interval
+func importProgress(c *gumble.Client) func(done, total int) {
+	var mutex sync.Mutex
+	last := time.Now()
+	return func(done, total int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch {
+		case done == 0 && total > 1:
+			c.Self.Channel.Send(fmt.Sprintf("Adding %d songs from the playlist", total), false)
+		case done < total && time.Since(last) >= importProgressInterval:
+			last = time.Now()
+			c.Self.Channel.Send(fmt.Sprintf("Fetched %d of %d songs", done, total), false)
+		}
 	}
+}
 
-	log.Fatalf("Disconnect reason is %s: %s\n", reason, e.String)
+// Returns a function to handle the disconnect event
+// It passes the event to the connection loop
+func handleDisconnect(disconnects chan *gumble.DisconnectEvent) func(e *gumble.DisconnectEvent) {
+	return func(e *gumble.DisconnectEvent) {
+		disconnects <- e
+	}
 }
 
 // Gets the list of next songs and prints them
@@ -213,41 +429,122 @@ func onCurrentSong(p *player.Player) (string, error) {
 }
 
 // Starts the playlist and returns the corresponding answer or an error
-func onStart(p *player.Player, c *gumble.Client) (string, error) {
+func onStart(p *player.Player) (string, error) {
 	response := "Playlist started"
-	if playErr := p.Start(c); playErr != nil {
+	if playErr := p.Start(); playErr != nil {
 		return "", playErr
 	}
 
 	return response, nil
 }
 
-// Adds the URL to the playlist and returns the corresponding answer or an error
-func onAdd(p *player.Player, c *gumble.Client, words []string) (string, error) {
+// Adds the URL to the playlist and returns the corresponding answer or an error.
+// The progress of playlists is reported to the channel of the client.
+func onAdd(p *player.Player, words []string, config *Config, c *gumble.Client) (string, error) {
+	mode, words, err := parsePlaylistFlag(words, config.PlaylistMode)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := findURL(words)
+	if err != nil {
+		return "", err
+	}
+
+	tracks, err := p.AddToQueue(url, mode, importProgress(c))
+	skipped, err := skippedVideos(err)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tracks) == 1 && skipped == "" {
+		return fmt.Sprintf("Added: %v", tracks[0]), nil
+	}
+	return fmt.Sprintf("<h4>Added %d songs to the queue<br></h4>%s", len(tracks), skipped), nil
+}
+
+// Adds the URL to the start of the playlist and returns the corresponding answer or an error.
+// The progress of playlists is reported to the channel of the client.
+func onPlayNext(p *player.Player, words []string, config *Config, c *gumble.Client) (string, error) {
+	mode, words, err := parsePlaylistFlag(words, config.PlaylistMode)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := findURL(words)
+	if err != nil {
+		return "", err
+	}
+
+	tracks, err := p.PlayNext(url, mode, importProgress(c))
+	skipped, err := skippedVideos(err)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tracks) == 1 && skipped == "" {
+		return fmt.Sprintf("Playing next: %v", tracks[0]), nil
+	}
+	return fmt.Sprintf("<h4>Added %d songs to the start of the queue<br></h4>%s", len(tracks), skipped), nil
+}
+
+// Removes a song or a range of songs from the playlist and returns the corresponding answer or an error
+func onRemove(p *player.Player, words []string) (string, error) {
 	if len(words) < 2 {
 		return "", ErrTooFewArgs
 	}
 
-	regex := xurls.Strict()
-	rawURL := regex.FindString(strings.Join(words[1:], " "))
-	if rawURL == "" {
-		return "", ErrNoURLFound
+	from, to, err := parseRange(words[1])
+	if err != nil {
+		return "", err
+	}
+
+	tracks, err := p.RemoveTracks(from, to)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tracks) == 1 {
+		return fmt.Sprintf("Removed: %s", tracks[0].Title), nil
+	}
+	return fmt.Sprintf("Removed %d songs from the queue", len(tracks)), nil
+}
+
+// Moves a song to another position in the playlist and returns the corresponding answer or an error
+func onMove(p *player.Player, words []string) (string, error) {
+	if len(words) < 3 {
+		return "", ErrTooFewArgs
 	}
 
-	url, err := url.Parse(rawURL)
+	from, to, err := parsePositions(words[1], words[2])
 	if err != nil {
 		return "", err
 	}
 
-	tracks, err := p.AddToQueue(c, url)
+	track, err := p.MoveTrack(from, to)
 	if err != nil {
 		return "", err
 	}
 
-	if len(tracks) == 1 {
-		return fmt.Sprintf("Added: %v", tracks[0]), nil
+	return fmt.Sprintf("Moved %s to position %d", track.Title, to), nil
+}
+
+// Swaps two songs of the playlist and returns the corresponding answer or an error
+func onSwap(p *player.Player, words []string) (string, error) {
+	if len(words) < 3 {
+		return "", ErrTooFewArgs
 	}
-	return fmt.Sprintf("<h4>Added %d songs to the queue<br></h4>", len(tracks)), nil
+
+	a, b, err := parsePositions(words[1], words[2])
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.SwapTracks(a, b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Swapped songs %d and %d", a, b), nil
 }
 
 // Stops the playlist and returns the corresponding answer or an error
@@ -259,17 +556,55 @@ func onStop(p *player.Player) (string, error) {
 	return "Playlist stopped", nil
 }
 
+// Pauses the playlist and returns the corresponding answer or an error
+func onPause(p *player.Player) (string, error) {
+	if err := p.Pause(); err != nil {
+		return "", err
+	}
+
+	return "Playlist paused", nil
+}
+
+// Resumes the playlist and returns the corresponding answer or an error
+func onResume(p *player.Player) (string, error) {
+	if err := p.Resume(); err != nil {
+		return "", err
+	}
+
+	return "Playlist resumed", nil
+}
+
+// Seeks the current song and returns the corresponding answer or an error
+func onSeek(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		return "", ErrTooFewArgs
+	}
+
+	position, relative, err := parseSeekTime(words[1])
+	if err != nil {
+		return "", err
+	}
+
+	position, err = p.Seek(position, relative)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Moved to %s", formatSeekTime(position)), nil
+}
+
 // Adds the track matching the search to the playlist and returns the corresponding answer or a error
-func onSearch(p *player.Player, c *gumble.Client, words []string, config *Config) (string, error) {
-	if config.YoutubeAPIKey == "" {
-		return "", ErrNoYoutubeAPIKey
+func onSearchFirst(p *player.Player, words []string, config *Config) (string, error) {
+	search, err := getSearcher(config)
+	if err != nil {
+		return "", err
 	}
 
 	if len(words) < 2 {
 		return "", ErrTooFewArgs
 	}
 
-	track, err := p.SearchAndAdd(c, config.YoutubeAPIKey, strings.Join(words[1:], " "))
+	track, err := p.SearchAndAdd(search, strings.Join(words[1:], " "))
 	if err != nil {
 		return "", err
 	}
@@ -278,6 +613,199 @@ func onSearch(p *player.Player, c *gumble.Client, words []string, config *Config
 	return fmt.Sprintf("Added: %v", track), nil
 }
 
+// Searches youtube and returns the top results for the user to pick from or an error
+func onSearch(words []string, config *Config, searches *pendingSearches, user string) (string, error) {
+	search, err := getSearcher(config)
+	if err != nil {
+		return "", err
+	}
+
+	if len(words) < 2 {
+		return "", ErrTooFewArgs
+	}
+
+	results, err := search(strings.Join(words[1:], " "), config.SearchResults)
+	if err != nil {
+		return "", err
+	}
+
+	searches.set(user, results)
+	return formatSearchResults(results, config.Prefix), nil
+}
+
+// Returns the numbered list of the search results for displaying.
+// The titles and channels are plain text so they are escaped.
+func formatSearchResults(results []youtube_search.Result, prefix string) string {
+	response := "<br>"
+	for i, result := range results {
+		duration := "?"
+		if result.Duration > 0 {
+			duration = formatSeekTime(result.Duration)
+		}
+		response += fmt.Sprintf("<b>%d: %s</b> by %s (%s)<br>", i+1, html.EscapeString(result.Title), html.EscapeString(result.Channel), duration)
+	}
+	response += fmt.Sprintf("Add one with %spick $NUM", prefix)
+	return response
+}
+
+// Adds the search result of the user at the given position
+// and returns the corresponding answer or an error
+func onPick(p *player.Player, words []string, searches *pendingSearches, user string) (string, error) {
+	if len(words) < 2 {
+		return "", ErrTooFewArgs
+	}
+
+	n, err := strconv.Atoi(words[1])
+	if err != nil {
+		return "", ErrInvalidNumber
+	}
+
+	result, err := searches.pick(user, n)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(result.URL())
+	if err != nil {
+		return "", err
+	}
+
+	tracks, err := p.AddToQueue(u, player.PlaylistVideo, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Added: %v", tracks[0]), nil
+}
+
+// Returns the name of the user that sent the message
+// or an empty string if it was not sent by a user
+func senderName(e *gumble.TextMessageEvent) string {
+	if e.Sender == nil {
+		return ""
+	}
+
+	return e.Sender.Name
+}
+
+// Adds the song matching the query from the music library and returns the corresponding answer or an error
+func onLocal(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		return "", ErrTooFewArgs
+	}
+
+	track, matches, err := p.AddLocal(strings.Join(words[1:], " "))
+	if err != nil {
+		return "", err
+	}
+
+	if matches > 1 {
+		return fmt.Sprintf("Found %d songs, added the first one: %v", matches, track), nil
+	}
+	return fmt.Sprintf("Added: %v", track), nil
+}
+
+// Adds the songs of the album from the music library and returns the corresponding answer or an error
+func onAlbum(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		return "", ErrTooFewArgs
+	}
+
+	tracks, err := p.AddAlbum(strings.Join(words[1:], " "))
+	skipped, err := skippedVideos(err)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("<h4>Added %d songs of the album to the queue<br></h4>%s", len(tracks), skipped), nil
+}
+
+// Scans the music library in the background and returns the corresponding answer.
+// The result of the scan is sent when it finishes.
+func onRescan(p *player.Player, c *gumble.Client) string {
+	go func() {
+		count, err := p.RescanLibrary()
+		if handleError(err, c) {
+			c.Self.Channel.Send(fmt.Sprintf("Found %d songs in the library", count), false)
+		}
+	}()
+
+	return "Scanning the library"
+}
+
+// Lists the episodes of the podcast feed or adds the episode at the
+// given position and returns the corresponding answer or an error
+func onPodcast(p *player.Player, words []string) (string, error) {
+	feedURL, err := findURL(words)
+	if err != nil {
+		return "", err
+	}
+
+	// The position is the last argument after the URL
+	if len(words) > 2 {
+		if n, err := strconv.Atoi(words[len(words)-1]); err == nil {
+			track, err := p.AddEpisode(feedURL, n)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("Added: %v", track), nil
+		}
+	}
+
+	return p.GetEpisodes(feedURL)
+}
+
+// Plays, saves, removes or lists the radio stations
+// and returns the corresponding answer or an error
+func onRadio(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		return p.GetStations()
+	}
+
+	switch words[1] {
+	case "add":
+		if len(words) < 4 {
+			return "", ErrTooFewArgs
+		}
+
+		// The name cannot be a subcommand or it could not be played
+		name := words[2]
+		if name == "add" || name == "remove" {
+			return "", ErrStationName
+		}
+
+		url, err := findURL(words[2:])
+		if err != nil {
+			return "", err
+		}
+
+		track, err := p.AddStation(name, url)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Saved station %s: %v", name, track), nil
+	case "remove":
+		if len(words) < 3 {
+			return "", ErrTooFewArgs
+		}
+
+		if err := p.RemoveStation(words[2]); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Removed station %s", words[2]), nil
+	}
+
+	track, err := p.PlayStation(words[1])
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Playing station: %v", track), nil
+}
+
 // Skips the song and returns the corresponding answer or an error
 func onSkip(p *player.Player) (string, error) {
 	if err := p.Skip(); err != nil {
@@ -287,11 +815,62 @@ func onSkip(p *player.Player) (string, error) {
 	return "Song skipped", nil
 }
 
+// Plays the previous song and returns the corresponding answer or an error
+func onPrevious(p *player.Player) (string, error) {
+	track, err := p.Previous()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Playing previous: %v", track), nil
+}
+
+// Gets the list of played songs and prints them
+func onHistory(p *player.Player) (string, error) {
+	return p.GetHistory()
+}
+
 func onClear(p *player.Player) string {
 	p.ClearQueue()
 	return "Playlist cleared"
 }
 
+// Shuffles the playlist or sets the shuffle mode and returns the corresponding answer or an error
+func onShuffle(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		if err := p.Shuffle(); err != nil {
+			return "", err
+		}
+		return "Playlist shuffled", nil
+	}
+
+	switch words[1] {
+	case "on":
+		p.SetShuffle(true)
+		return "Shuffle turned on", nil
+	case "off":
+		p.SetShuffle(false)
+		return "Shuffle turned off", nil
+	default:
+		return "", ErrShuffleMode
+	}
+}
+
+// Sets the repeat mode and returns the corresponding answer or an error
+func onRepeat(p *player.Player, words []string) (string, error) {
+	if len(words) < 2 {
+		return fmt.Sprintf("Repeat is %v", p.GetRepeat()), nil
+	}
+
+	mode, err := player.ParseRepeatMode(words[1])
+	if err != nil {
+		return "", err
+	}
+
+	p.SetRepeat(mode)
+	return fmt.Sprintf("Repeat set to %v", mode), nil
+}
+
 // Sets the volume and returns the corresponding answer or an error
 func onVolume(p *player.Player, words []string) (string, error) {
 
@@ -309,6 +888,173 @@ func onVolume(p *player.Player, words []string) (string, error) {
 	return fmt.Sprintf("Volume set to %d", value), err
 }
 
+// Finds and returns the first URL in the command arguments
+func findURL(words []string) (*url.URL, error) {
+	if len(words) < 2 {
+		return nil, ErrTooFewArgs
+	}
+
+	regex := xurls.Strict()
+	rawURL := regex.FindString(strings.Join(words[1:], " "))
+	if rawURL == "" {
+		return nil, ErrNoURLFound
+	}
+
+	return url.Parse(rawURL)
+}
+
+// Returns the videos that were skipped when adding a playlist for
+// displaying if the error is a SkippedError, or the error otherwise
+func skippedVideos(err error) (string, error) {
+	var skipped *player.SkippedError
+	if !errors.As(err, &skipped) {
+		return "", err
+	}
+
+	return formatSkipped(skipped), nil
+}
+
+// Returns the number of skipped videos and the reasons for the first few.
+// The videos skipped because the playlist is full are only counted.
+func formatSkipped(e *player.SkippedError) string {
+	res := ""
+	failed := 0
+	for _, video := range e.Skipped {
+		if errors.Is(video.Err, player.ErrQueueFull) {
+			continue
+		}
+
+		if failed < MaxSkippedShown {
+			res += fmt.Sprintf("%s: %s<br>", video.Title, video.Reason())
+		}
+		failed++
+	}
+
+	if failed > MaxSkippedShown {
+		res += fmt.Sprintf("and %d more<br>", failed-MaxSkippedShown)
+	}
+
+	if failed > 0 {
+		res = fmt.Sprintf("Skipped %d songs:<br>", failed) + res
+	}
+
+	if full := len(e.Skipped) - failed; full > 0 {
+		res += fmt.Sprintf("%d songs were not added because the playlist is full<br>", full)
+	}
+	return res
+}
+
+// Removes the --playlist flag from the command arguments and returns
+// the playlist mode it sets, or the default mode if there is no flag.
+// The flag without a value adds the whole playlist.
+func parsePlaylistFlag(words []string, defaultMode player.PlaylistMode) (player.PlaylistMode, []string, error) {
+	mode := defaultMode
+	rest := make([]string, 0, len(words))
+	for _, word := range words {
+		switch {
+		case word == "--playlist":
+			mode = player.PlaylistAll
+		case strings.HasPrefix(word, "--playlist="):
+			var err error
+			mode, err = player.ParsePlaylistMode(strings.TrimPrefix(word, "--playlist="))
+			if err != nil {
+				return defaultMode, nil, err
+			}
+		default:
+			rest = append(rest, word)
+		}
+	}
+
+	return mode, rest, nil
+}
+
+// Parses a position "N" or a range of positions "A-B"
+// and returns the first and the last position
+func parseRange(arg string) (int, int, error) {
+	parts := strings.SplitN(arg, "-", 2)
+	if len(parts) == 1 {
+		return parsePositions(parts[0], parts[0])
+	}
+
+	return parsePositions(parts[0], parts[1])
+}
+
+// Parses two queue positions
+func parsePositions(first, second string) (int, int, error) {
+	a, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, ErrInvalidNumber
+	}
+
+	b, err := strconv.Atoi(second)
+	if err != nil {
+		return 0, 0, ErrInvalidNumber
+	}
+
+	return a, b, nil
+}
+
+// Parses a time like "1:23", "1:02:03", "83", "30s" or "1m30s".
+// A leading "+" or "-" makes the time relative to the current position.
+func parseSeekTime(arg string) (time.Duration, bool, error) {
+	relative := strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+	negative := strings.HasPrefix(arg, "-")
+	if relative {
+		arg = arg[1:]
+	}
+
+	// Only one sign is allowed
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		return 0, false, ErrInvalidTime
+	}
+
+	var d time.Duration
+	switch {
+	case strings.Contains(arg, ":"):
+		parts := strings.Split(arg, ":")
+		if len(parts) > 3 {
+			return 0, false, ErrInvalidTime
+		}
+
+		for _, part := range parts {
+			value, err := strconv.Atoi(part)
+			if err != nil || value < 0 {
+				return 0, false, ErrInvalidTime
+			}
+			d = d*60 + time.Duration(value)*time.Second
+		}
+	default:
+		if seconds, err := strconv.Atoi(arg); err == nil {
+			d = time.Duration(seconds) * time.Second
+			break
+		}
+
+		parsed, err := time.ParseDuration(arg)
+		if err != nil || parsed < 0 {
+			return 0, false, ErrInvalidTime
+		}
+		d = parsed
+	}
+
+	if negative {
+		d = -d
+	}
+
+	return d, relative, nil
+}
+
+// Formats a seek position as "m:ss" or "h:mm:ss"
+func formatSeekTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	s := (d % time.Minute) / time.Second
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
 // Receives an error and responds accordingly
 // Returns true if the error is nil
 func handleError(err error, c *gumble.Client) bool {
@@ -324,6 +1070,40 @@ func handleError(err error, c *gumble.Client) bool {
 		response = "The playlist is empty"
 	case errors.Is(err, player.ErrStopped):
 		response = "The playlist is already stopped"
+	case errors.Is(err, player.ErrPaused):
+		response = "The playlist is already paused"
+	case errors.Is(err, player.ErrNotPaused):
+		response = "The playlist is not paused"
+	case errors.Is(err, player.ErrSeekRange):
+		response = "The time is outside of the song"
+	case errors.Is(err, player.ErrLiveStream):
+		response = "Cannot seek in a live stream"
+	case errors.Is(err, player.ErrNoEpisodes):
+		response = "The podcast has no episodes"
+	case errors.Is(err, player.ErrFeed):
+		response = "Could not read the podcast feed"
+	case errors.Is(err, player.ErrNoStation):
+		response = "There is no saved station with that name"
+	case errors.Is(err, player.ErrNotStation):
+		response = "The URL is not a radio station or a station playlist"
+	case errors.Is(err, player.ErrNoStations):
+		response = "There are no saved stations. Save one with radio add $NAME $URL."
+	case errors.Is(err, ErrStationName):
+		response = "The station name cannot be add or remove"
+	case errors.Is(err, ErrInvalidTime):
+		response = "Could not read the time given"
+	case errors.Is(err, player.ErrRepeatMode):
+		response = "The repeat mode must be one, all or off"
+	case errors.Is(err, player.ErrPlaylistMode):
+		response = "The playlist mode must be video, all or from"
+	case errors.Is(err, ErrShuffleMode):
+		response = "Shuffle must be on or off"
+	case errors.Is(err, player.ErrEmptyHistory):
+		response = "No songs have been played yet"
+	case errors.Is(err, player.ErrNoLibrary):
+		response = "The bot has not been configured with a music library. Add a library path in the config."
+	case errors.Is(err, player.ErrNoMatch):
+		response = "No matching songs found"
 	case errors.Is(err, player.ErrNoFormat):
 		response = "Could not find correct format for song"
 	case errors.Is(err, player.ErrVolumeRange):
@@ -338,10 +1118,20 @@ func handleError(err error, c *gumble.Client) bool {
 		response = "Too few arguments given"
 	case errors.Is(err, ErrNoURLFound):
 		response = "Could not find URL"
+	case errors.Is(err, ErrNoSearchResults):
+		response = "You have no recent search results. Search for songs first."
+	case errors.Is(err, ErrSearchBackend):
+		response = "The search backend in the config must be auto, api or web"
 	case errors.Is(err, ErrNoYoutubeAPIKey):
-		response = "The bot has not been configured to search youtube. Add a Youtube API key in the config."
+		response = "The bot has not been configured to search youtube. Add a Youtube API key in the config or set search_backend to web."
 	case errors.Is(err, player.ErrEmptyPlaylist):
 		response = "The playlist has 0 videos or is non existant"
+	case errors.Is(err, player.ErrIndexRange):
+		response = "There is no song at that position in the playlist"
+	case errors.Is(err, ErrInvalidNumber):
+		response = "Could not read the number given"
+	case errors.Is(err, player.ErrQueueFull):
+		response = "The playlist is full. Remove some songs or raise max_queue_size in the config."
 	default:
 		response = err.Error()
 	}
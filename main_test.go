@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSeekTime(t *testing.T) {
+	tests := []struct {
+		arg      string
+		want     time.Duration
+		relative bool
+		err      error
+	}{
+		{"83", 83 * time.Second, false, nil},
+		{"0", 0, false, nil},
+		{"1:23", 83 * time.Second, false, nil},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second, false, nil},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false, nil},
+		{"30s", 30 * time.Second, false, nil},
+		{"1m30s", 90 * time.Second, false, nil},
+		{"+30s", 30 * time.Second, true, nil},
+		{"+30", 30 * time.Second, true, nil},
+		{"+1:00", time.Minute, true, nil},
+		// A negative time is always relative to the current position
+		{"-10s", -10 * time.Second, true, nil},
+		{"-10", -10 * time.Second, true, nil},
+		{"-1:30", -90 * time.Second, true, nil},
+		{"-", 0, false, ErrInvalidTime},
+		{"+", 0, false, ErrInvalidTime},
+		{"", 0, false, ErrInvalidTime},
+		{"--10", 0, false, ErrInvalidTime},
+		{"+-10", 0, false, ErrInvalidTime},
+		{"1:-2", 0, false, ErrInvalidTime},
+		{"1::2", 0, false, ErrInvalidTime},
+		{"1:", 0, false, ErrInvalidTime},
+		{"1:2:3:4", 0, false, ErrInvalidTime},
+		{"soon", 0, false, ErrInvalidTime},
+		{"1h-5m", 0, false, ErrInvalidTime},
+	}
+
+	for _, test := range tests {
+		got, relative, err := parseSeekTime(test.arg)
+		if err != test.err {
+			t.Errorf("%q: got error %v, want %v", test.arg, err, test.err)
+			continue
+		}
+
+		if got != test.want || relative != test.relative {
+			t.Errorf("%q: got %v relative %v, want %v relative %v", test.arg, got, relative, test.want, test.relative)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		arg      string
+		from, to int
+		err      error
+	}{
+		{"3", 3, 3, nil},
+		{"2-5", 2, 5, nil},
+		{"5-5", 5, 5, nil},
+		// A reversed range is rejected by the queue with its own error
+		{"5-2", 5, 2, nil},
+		{"-", 0, 0, ErrInvalidNumber},
+		{"3-", 0, 0, ErrInvalidNumber},
+		{"-3", 0, 0, ErrInvalidNumber},
+		{"1-2-3", 0, 0, ErrInvalidNumber},
+		{"a-b", 0, 0, ErrInvalidNumber},
+		{"", 0, 0, ErrInvalidNumber},
+	}
+
+	for _, test := range tests {
+		from, to, err := parseRange(test.arg)
+		if err != test.err {
+			t.Errorf("%q: got error %v, want %v", test.arg, err, test.err)
+			continue
+		}
+
+		if from != test.from || to != test.to {
+			t.Errorf("%q: got %d-%d, want %d-%d", test.arg, from, to, test.from, test.to)
+		}
+	}
+}
+
+func TestFormatSeekTime(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                "0:00",
+		83 * time.Second: "1:23",
+		time.Hour + 2*time.Minute + 3*time.Second: "1:02:03",
+		1500 * time.Millisecond:                   "0:02",
+	}
+
+	for d, want := range tests {
+		if got := formatSeekTime(d); got != want {
+			t.Errorf("%v: got %s, want %s", d, got, want)
+		}
+	}
+}
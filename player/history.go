@@ -0,0 +1,92 @@
+package player
+
+import (
+	"errors"
+	"strconv"
+)
+
+const MaxHistorySize = 20
+
+var ErrEmptyHistory = errors.New("the history is empty")
+
+// Adds a played track to the history and drops
+// the oldest one if the history is full. The mutex must be held.
+func (p *Player) addToHistory(t *Track) {
+	p.history = append(p.history, t)
+	if len(p.history) > MaxHistorySize {
+		p.history = p.history[len(p.history)-MaxHistorySize:]
+	}
+}
+
+// Returns the played tracks, the most recent first
+func (p *Player) GetHistory() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.history) == 0 {
+		return "", ErrEmptyHistory
+	}
+
+	songlist := "<br><b>"
+	for i := len(p.history) - 1; i >= 0; i-- {
+		songlist += strconv.Itoa(len(p.history)-i) + ": " + p.history[i].Title + "<br>"
+	}
+	songlist += "</b>"
+	return songlist, nil
+}
+
+// Removes the last played track from the history and plays it.
+// The current track is played again after it.
+// Returns the track that is played.
+func (p *Player) Previous() (*Track, error) {
+	p.mutex.Lock()
+	if len(p.history) == 0 {
+		p.mutex.Unlock()
+		return nil, ErrEmptyHistory
+	}
+
+	last := p.history[len(p.history)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.mutex.Unlock()
+
+	// The stream URL of the track may have expired
+	// so the track is fetched again from its public URL.
+	track, err := p.sources.Track(last.PublicURL)
+	if err != nil {
+		p.mutex.Lock()
+		p.addToHistory(last)
+		p.mutex.Unlock()
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if p.queueSpace() == 0 {
+		p.addToHistory(last)
+		return nil, ErrQueueFull
+	}
+
+	// The tracks are played in order even if shuffle is on
+	p.queue.Insert(0, track)
+	p.pickedTracks++
+	switch {
+	case p.playing && p.currentTrack != nil:
+		// The playlist puts the current track back in the
+		// queue after the previous one when its stream stops.
+		p.seeking = false
+		p.previous = true
+		p.currentTrack.stopStream()
+	case p.paused:
+		p.currentTrack.Offset = 0
+		p.queue.Insert(1, p.currentTrack)
+		p.pickedTracks++
+		p.currentTrack = nil
+		p.startLocked()
+	case !p.playing:
+		p.startLocked()
+	}
+
+	return track, nil
+}
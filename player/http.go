@@ -0,0 +1,109 @@
+package player
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// The extensions of the audio files that are recognized without a request
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".flac": true,
+	".wav":  true,
+	".m4a":  true,
+	".aac":  true,
+}
+
+// How long the response to a request for an audio file can take
+const httpRequestTimeout = 30 * time.Second
+
+// The source for audio files served over HTTP
+type HTTPSource struct {
+	client *http.Client
+	// Reads the metadata of a URL
+	probe func(string) (*probeInfo, error)
+}
+
+// Creates and returns an HTTP source that reads metadata with ffprobe
+func NewHTTPSource() *HTTPSource {
+	return &HTTPSource{
+		client: &http.Client{Timeout: httpRequestTimeout},
+		probe:  probe,
+	}
+}
+
+// Returns whether the URL is an audio file. The extension of the URL is
+// checked first and if it is unknown the content type of the response.
+func (s *HTTPSource) CanHandle(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	if audioExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+
+	resp, err := s.client.Head(u.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK && isAudioContentType(resp.Header.Get("Content-Type"))
+}
+
+// Returns the track of the audio file. The metadata are read from the tags
+// of the file and if there are no tags the title is the file name.
+func (s *HTTPSource) Tracks(u *url.URL) ([]*Track, error) {
+	info, err := s.probe(u.String())
+	if err != nil {
+		log.Printf("Could not read the metadata of %s: %v\n", u, err)
+		info = new(probeInfo)
+	}
+
+	if info.Title == "" {
+		info.Title = fileTitle(u.Path)
+	}
+
+	if info.Artist == "" {
+		info.Artist = u.Host
+	}
+
+	streamURL := u.String()
+	return []*Track{{
+		Title:      info.Title,
+		Artist:     info.Artist,
+		Duration:   info.Duration,
+		PublicURL:  streamURL,
+		StreamURL:  streamURL,
+		resolveURL: func() (string, error) { return streamURL, nil },
+	}}, nil
+}
+
+// Returns whether the content type is an audio type
+func isAudioContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "audio/") || mediaType == "application/ogg"
+}
+
+// Returns the name of the file without its extension
+func fileTitle(p string) string {
+	name := path.Base(p)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+
+	return strings.TrimSuffix(name, path.Ext(name))
+}
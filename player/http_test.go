@@ -0,0 +1,127 @@
+package player
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Returns a server that answers with the content type of each path
+// and the number of requests it received
+func audioServer(t *testing.T, contentTypes map[string]string) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		contentType, ok := contentTypes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// Returns an HTTP source that uses the client of the server and the probe
+func testHTTPSource(server *httptest.Server, probe func(string) (*probeInfo, error)) *HTTPSource {
+	return &HTTPSource{client: server.Client(), probe: probe}
+}
+
+func TestHTTPSourceExtension(t *testing.T) {
+	server, requests := audioServer(t, nil)
+	s := testHTTPSource(server, nil)
+
+	for _, path := range []string{"/song.mp3", "/a/b/Song.FLAC", "/song.opus"} {
+		u, _ := url.Parse(server.URL + path)
+		if !s.CanHandle(u) {
+			t.Errorf("%s is not recognized", path)
+		}
+	}
+
+	if *requests != 0 {
+		t.Fatalf("got %d requests, want none for known extensions", *requests)
+	}
+}
+
+func TestHTTPSourceContentType(t *testing.T) {
+	server, _ := audioServer(t, map[string]string{
+		"/stream":   "audio/mpeg",
+		"/vorbis":   "application/ogg",
+		"/charset":  "audio/ogg; codecs=opus",
+		"/page":     "text/html; charset=utf-8",
+		"/video":    "video/mp4",
+		"/page.mp4": "video/mp4",
+	})
+	s := testHTTPSource(server, nil)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/stream", true},
+		{"/vorbis", true},
+		{"/charset", true},
+		{"/page", false},
+		{"/video", false},
+		{"/page.mp4", false},
+		{"/missing", false},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(server.URL + test.path)
+		if got := s.CanHandle(u); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.path, got, test.want)
+		}
+	}
+
+	u, _ := url.Parse("ftp://example.com/song.mp3")
+	if s.CanHandle(u) {
+		t.Error("a URL that is not HTTP is recognized")
+	}
+}
+
+func TestHTTPSourceTracks(t *testing.T) {
+	server, _ := audioServer(t, map[string]string{"/stream": "audio/mpeg"})
+	s := testHTTPSource(server, func(string) (*probeInfo, error) {
+		return &probeInfo{Title: "Song", Artist: "Band", Duration: time.Minute}, nil
+	})
+
+	u, _ := url.Parse(server.URL + "/stream")
+	tracks, err := s.Tracks(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track := tracks[0]
+	if len(tracks) != 1 || track.Title != "Song" || track.Artist != "Band" || track.Duration != time.Minute {
+		t.Fatalf("got track %+v", track)
+	}
+
+	if track.StreamURL != u.String() || track.PublicURL != u.String() {
+		t.Fatalf("got URLs %s and %s, want %s", track.StreamURL, track.PublicURL, u)
+	}
+}
+
+func TestHTTPSourceProbeFails(t *testing.T) {
+	server, _ := audioServer(t, nil)
+	s := testHTTPSource(server, func(string) (*probeInfo, error) {
+		return nil, errors.New("no ffprobe")
+	})
+
+	u, _ := url.Parse(server.URL + "/music/My%20Song.ogg")
+	tracks, err := s.Tracks(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tracks[0].Title != "My Song" || tracks[0].Artist != u.Host {
+		t.Fatalf("got title %q by %q, want the file name by the host", tracks[0].Title, tracks[0].Artist)
+	}
+}
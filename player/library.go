@@ -0,0 +1,259 @@
+package player
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoLibrary = errors.New("no music library configured")
+	ErrNoMatch   = errors.New("no matching tracks found")
+)
+
+// An audio file of the library and its tags
+type libraryEntry struct {
+	Path        string
+	Title       string
+	Artist      string
+	Album       string
+	TrackNumber int
+	Duration    time.Duration
+}
+
+// The source for the audio files of a local directory. The files are
+// indexed by their tags so that they can be searched.
+type Library struct {
+	root    string
+	entries []*libraryEntry
+	mutex   *sync.RWMutex
+}
+
+// Creates and returns a library for the directory. It is empty until it is scanned.
+func NewLibrary(root string) (*Library, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Library{
+		root:    root,
+		entries: make([]*libraryEntry, 0),
+		mutex:   new(sync.RWMutex),
+	}, nil
+}
+
+// Scans the directory recursively for audio files, reads
+// their tags and replaces the index. Returns the number of files found.
+func (l *Library) Scan() (int, error) {
+	entries := make([]*libraryEntry, 0)
+	err := filepath.Walk(l.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		entry, err := newLibraryEntry(path)
+		if err != nil {
+			log.Printf("Could not read the tags of %s: %v\n", path, err)
+			return nil
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	l.mutex.Lock()
+	l.entries = entries
+	l.mutex.Unlock()
+
+	return len(entries), nil
+}
+
+// Returns the files that match all the words of the query
+// in their title, artist or album, in the order they were found
+func (l *Library) search(query string) []*libraryEntry {
+	words := strings.Fields(strings.ToLower(query))
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	entries := make([]*libraryEntry, 0)
+	for _, e := range l.entries {
+		text := strings.ToLower(strings.Join([]string{e.Title, e.Artist, e.Album}, " "))
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// Returns the files of the album with the name in track order.
+// An album with exactly the same name is preferred over one that contains it.
+func (l *Library) album(name string) []*libraryEntry {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	album := ""
+	for _, e := range l.entries {
+		entryAlbum := strings.ToLower(e.Album)
+		if entryAlbum == name {
+			album = e.Album
+			break
+		}
+
+		if album == "" && entryAlbum != "" && strings.Contains(entryAlbum, name) {
+			album = e.Album
+		}
+	}
+
+	if album == "" {
+		return nil
+	}
+
+	entries := make([]*libraryEntry, 0)
+	for _, e := range l.entries {
+		if e.Album == album {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].TrackNumber != entries[j].TrackNumber {
+			return entries[i].TrackNumber < entries[j].TrackNumber
+		}
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries
+}
+
+// Returns whether the URL is a file URL inside the library
+func (l *Library) CanHandle(u *url.URL) bool {
+	return u.Scheme == "file" && l.contains(u.Path)
+}
+
+// Returns the track of the file URL
+func (l *Library) Tracks(u *url.URL) ([]*Track, error) {
+	path := filepath.Clean(u.Path)
+
+	l.mutex.RLock()
+	for _, e := range l.entries {
+		if e.Path == path {
+			l.mutex.RUnlock()
+			return []*Track{e.track()}, nil
+		}
+	}
+	l.mutex.RUnlock()
+
+	// The file may have been added after the last scan
+	entry, err := newLibraryEntry(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*Track{entry.track()}, nil
+}
+
+// Returns whether the path is inside the library directory
+func (l *Library) contains(path string) bool {
+	rel, err := filepath.Rel(l.root, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Reads the tags of the file and returns its library entry
+func newLibraryEntry(path string) (*libraryEntry, error) {
+	info, err := probe(path)
+	if err != nil {
+		return nil, err
+	}
+
+	title := info.Title
+	if title == "" {
+		title = fileTitle(path)
+	}
+
+	return &libraryEntry{
+		Path:        path,
+		Title:       title,
+		Artist:      info.Artist,
+		Album:       info.Album,
+		TrackNumber: parseTrackNumber(info.TrackNumber),
+		Duration:    info.Duration,
+	}, nil
+}
+
+// Returns a new track for the file. The embedded
+// cover art of the file is used as the thumbnail.
+func (e *libraryEntry) track() *Track {
+	path := e.Path
+	return &Track{
+		Title:      e.Title,
+		Artist:     e.Artist,
+		Duration:   e.Duration,
+		PublicURL:  (&url.URL{Scheme: "file", Path: path}).String(),
+		StreamURL:  path,
+		Thumbnail:  extractCover(path),
+		resolveURL: func() (string, error) { return path, nil },
+	}
+}
+
+// Parses a track number tag like "3" or "3/12"
+func parseTrackNumber(tag string) int {
+	number, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(tag, "/", 2)[0]))
+	if err != nil {
+		return 0
+	}
+
+	return number
+}
+
+// Returns the embedded cover art of the file or nil
+// if it has none or the cover is too large to show
+func extractCover(path string) *Thumbnail {
+	out, err := exec.Command("ffmpeg", "-v", "quiet", "-i", path, "-an", "-c:v", "copy", "-frames:v", "1", "-f", "image2pipe", "-").Output()
+	if err != nil {
+		return nil
+	}
+
+	return coverThumbnail(out)
+}
+
+// Returns the thumbnail of the image of a cover
+// or nil if there is no image or it is too large
+func coverThumbnail(image []byte) *Thumbnail {
+	if len(image) == 0 {
+		return nil
+	}
+
+	thumbnail := NewThumbnailFromImage(image)
+	if len(thumbnail.Data) > maxArtworkSize {
+		return nil
+	}
+	return thumbnail
+}
@@ -0,0 +1,43 @@
+package player
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCoverThumbnail(t *testing.T) {
+	// The PNG signature makes the type detectable
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	small := coverThumbnail(append(png, make([]byte, 1024)...))
+	if small == nil || small.MimeType != "image/png" {
+		t.Fatalf("got thumbnail %+v for a small cover", small)
+	}
+
+	if coverThumbnail(nil) != nil {
+		t.Error("got a thumbnail without a cover")
+	}
+
+	// The encoded size is over the limit
+	large := append(png, bytes.Repeat([]byte{0}, maxArtworkSize)...)
+	if coverThumbnail(large) != nil {
+		t.Error("got a thumbnail for a cover that is too large")
+	}
+}
+
+func TestParseTrackNumber(t *testing.T) {
+	tests := map[string]int{
+		"3":    3,
+		"03":   3,
+		"3/12": 3,
+		" 7 ":  7,
+		"":     0,
+		"A1":   0,
+	}
+
+	for tag, want := range tests {
+		if got := parseTrackNumber(tag); got != want {
+			t.Errorf("%q: got %d, want %d", tag, got, want)
+		}
+	}
+}
@@ -3,21 +3,23 @@ package player
 import (
 	"errors"
 	"fmt"
+	"html"
 	"log"
-	"net/http"
+	"math/rand"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/evris99/mumble-music-bot/youtube_search"
-	"github.com/kkdai/youtube/v2"
+	"github.com/evris99/mumble-jackson/youtube_search"
 	"layeh.com/gumble/gumble"
 	"layeh.com/gumble/gumbleffmpeg"
 	_ "layeh.com/gumble/opus"
 )
 
-const MaxPlaylistSize = 100
+// The maximum number of tracks in the queue if it is not configured
+const DefaultMaxQueueSize = 100
 const MaxNextSongs = 20
 
 var (
@@ -28,111 +30,632 @@ var (
 	ErrVolumeRange   = errors.New("the volume level is incorrect")
 	ErrEmptyPlaylist = errors.New("playlist empty")
 	ErrIncorrectURL  = errors.New("incorrect url")
+	ErrQueueFull     = errors.New("the playlist is full")
+	ErrPaused        = errors.New("the playlist is already paused")
+	ErrNotPaused     = errors.New("the playlist is not paused")
+	ErrSeekRange     = errors.New("the position is outside of the track")
+	ErrStreamFailed  = errors.New("the stream stopped without playing")
+	ErrLiveStream    = errors.New("the track is a live stream")
 )
 
+// The configuration of the player
+type Config struct {
+	// The starting volume between 0 and 100
+	DefaultVolume uint8
+	// How long before the end of the current track the next one is resolved
+	PrefetchTime time.Duration
+	// How many tracks can fail in a row before the playlist stops.
+	// Zero means that the playlist never stops because of failures.
+	MaxFailures int
+	// The file the state of the player is saved to. Empty to not save it.
+	StateFile string
+	// The URLs of the saved radio stations by their name
+	Stations map[string]string
+	// The number of videos of a playlist that are fetched at the same
+	// time. Zero or less means the default number.
+	ImportWorkers int
+	// The maximum number of tracks in the queue. Zero means no limit.
+	MaxQueueSize int
+}
+
 type Player struct {
-	tracks       chan *Track
+	// The client the tracks are streamed to
+	client  *gumble.Client
+	sources *Registry
+	// The source of the saved radio stations
+	radio        *RadioSource
+	library      *Library
+	queue        *Queue
 	currentTrack *Track
-	SongList     []string
 	playing      bool
-	skip         chan bool
-	stop         chan bool
+	paused       bool
+	// Set when the stream of the current track has been replaced
+	// and the new one must be played instead of the next track
+	seeking bool
+	// Set when the current track is skipped so that it is not repeated
+	skipped bool
+	// Set when the previous track is played so that
+	// the current one is put back in the queue after it
+	previous bool
+	history  []*Track
+	repeat   RepeatMode
+	// Whether the next track is picked at random from the queue
+	shuffle bool
+	// Number of tracks at the start of the queue that are played
+	// in order before the next one is picked at random again
+	pickedTracks int
+	rng          *rand.Rand
 	volume       float32
-	streamMutex  *sync.Mutex
+	// How long before the end of the current track the next one is resolved
+	prefetchTime time.Duration
+	// The number of tracks that failed in a row
+	failures    int
+	maxFailures int
+	stateFile   string
+	// The maximum number of tracks in the queue. Zero means no limit.
+	maxQueueSize int
+	// The number of tracks that are resolved at the same
+	// time when a playlist or the saved state is loaded
+	importWorkers int
+	// The URLs of the saved radio stations by their lowercase name
+	stations map[string]string
+	// The stations of the config by their lowercase name. Only the
+	// changes to them are saved so that the config can be edited.
+	configStations map[string]string
+	// Set when the player is closed so that the state is not saved again
+	closed bool
+	// Closed when the playlist goroutine exits
+	done  chan bool
+	mutex *sync.Mutex
 }
 
 // Creates and returns a Player instance
-func New(default_volume uint8) *Player {
-	return &Player{
-		tracks:      make(chan *Track, MaxPlaylistSize),
-		playing:     false,
-		SongList:    make([]string, 0),
-		skip:        make(chan bool),
-		stop:        make(chan bool),
-		volume:      float32(default_volume) / 100,
-		streamMutex: new(sync.Mutex),
+func New(conf Config) *Player {
+	workers := conf.ImportWorkers
+	if workers <= 0 {
+		workers = DefaultImportWorkers
+	}
+
+	radio := NewRadioSource()
+	p := &Player{
+		queue:          NewQueue(),
+		sources:        NewRegistry(NewYoutubeSource(workers), radio, NewHTTPSource()),
+		radio:          radio,
+		playing:        false,
+		volume:         float32(conf.DefaultVolume) / 100,
+		prefetchTime:   conf.PrefetchTime,
+		maxFailures:    conf.MaxFailures,
+		stateFile:      conf.StateFile,
+		maxQueueSize:   conf.MaxQueueSize,
+		importWorkers:  workers,
+		stations:       make(map[string]string),
+		configStations: make(map[string]string),
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		mutex:          new(sync.Mutex),
+	}
+
+	for name, rawURL := range conf.Stations {
+		p.stations[strings.ToLower(name)] = rawURL
+		p.configStations[strings.ToLower(name)] = rawURL
 	}
+	return p
+}
+
+// Sets the client the tracks are streamed to. It must be
+// set before the playlist starts and again after reconnecting.
+func (p *Player) SetClient(c *gumble.Client) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.client = c
+}
+
+// Adds a source of tracks to the player. Sources must
+// be registered before tracks are added.
+func (p *Player) RegisterSource(s Source) {
+	p.sources.Register(s)
+}
+
+// Sets the local music library of the player and
+// registers it as a source. It must be set before tracks are added.
+func (p *Player) SetLibrary(l *Library) {
+	p.library = l
+	p.sources.Register(l)
+}
+
+// Scans the local music library again and returns the number of files found
+func (p *Player) RescanLibrary() (int, error) {
+	if p.library == nil {
+		return 0, ErrNoLibrary
+	}
+
+	return p.library.Scan()
+}
+
+// Adds the first track of the local music library that matches the query
+// to the playlist. Returns the added track and the number of matches.
+func (p *Player) AddLocal(query string) (*Track, int, error) {
+	if p.library == nil {
+		return nil, 0, ErrNoLibrary
+	}
+
+	entries := p.library.search(query)
+	if len(entries) == 0 {
+		return nil, 0, ErrNoMatch
+	}
+
+	track := entries[0].track()
+	if _, err := p.addTracks([]*Track{track}, false); err != nil {
+		return nil, 0, err
+	}
+	return track, len(entries), nil
+}
+
+// Adds the tracks of the album of the local music library to the playlist.
+// If some tracks do not fit in the playlist the rest are added and a
+// SkippedError is returned with them.
+func (p *Player) AddAlbum(name string) ([]*Track, error) {
+	if p.library == nil {
+		return nil, ErrNoLibrary
+	}
+
+	entries := p.library.album(name)
+	if len(entries) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	tracks := make([]*Track, len(entries))
+	for i, e := range entries {
+		tracks[i] = e.track()
+	}
+
+	added, err := p.addTracks(tracks, false)
+	if err != nil && !isPartial(added, err) {
+		return nil, err
+	}
+	return added, err
+}
+
+// Seeds the random generator used for shuffling
+func (p *Player) SetSeed(seed int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.rng = rand.New(rand.NewSource(seed))
 }
 
-// Starts the playlist
-func (p *Player) Start(c *gumble.Client) error {
+// Starts the playlist. If the playlist is paused it is resumed.
+func (p *Player) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
 	if p.playing {
 		return ErrPlaying
 	}
 
-	if len(p.tracks) == 0 {
+	if p.queue.Len() == 0 && p.currentTrack == nil {
 		return ErrEmpty
 	}
 
-	p.SongList = p.SongList[1:]
-	p.playing = true
-	go p.startPlaylist(c)
+	p.startLocked()
 	return nil
 }
 
-// Stops the playlist
+// Resumes the paused track from the position it was paused
+func (p *Player) Resume() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if !p.paused {
+		return ErrNotPaused
+	}
+
+	p.startLocked()
+	return nil
+}
+
+// Starts the playlist goroutine. The mutex must be held.
+func (p *Player) startLocked() {
+	p.playing = true
+	p.paused = false
+	p.done = make(chan bool)
+	go p.startPlaylist(p.done)
+}
+
+// Stops the playlist and waits for the current track to stop
 func (p *Player) Stop() error {
+	p.mutex.Lock()
+	if p.paused {
+		p.paused = false
+		p.currentTrack = nil
+		p.abandonPlaylist()
+		p.saveState()
+		p.mutex.Unlock()
+		return nil
+	}
+
+	if !p.playing {
+		p.mutex.Unlock()
+		return ErrStopped
+	}
+
+	p.playing = false
+	p.seeking = false
+	if p.resolving() {
+		p.currentTrack = nil
+		p.abandonPlaylist()
+		p.saveState()
+		p.mutex.Unlock()
+		return nil
+	}
+
+	if p.currentTrack != nil {
+		p.currentTrack.stopStream()
+	}
+	done := p.done
+	p.mutex.Unlock()
+
+	<-done
+	return nil
+}
+
+// Pauses the current track and waits for it to stop.
+// The track keeps its position and continues from it when resumed.
+func (p *Player) Pause() error {
+	p.mutex.Lock()
+	if p.paused {
+		p.mutex.Unlock()
+		return ErrPaused
+	}
+
 	if !p.playing {
+		p.mutex.Unlock()
 		return ErrStopped
 	}
+
+	if p.currentTrack == nil {
+		p.mutex.Unlock()
+		return ErrEmpty
+	}
+
 	p.playing = false
-	p.stop <- true
+	p.paused = true
+	p.seeking = false
+	if p.resolving() {
+		p.abandonPlaylist()
+		p.saveState()
+		p.mutex.Unlock()
+		return nil
+	}
+
+	p.currentTrack.stopStream()
+	done := p.done
+	p.mutex.Unlock()
+
+	<-done
 	return nil
 }
 
+// Returns whether the playlist goroutine is resolving the current
+// track. Resolving can hang on the network for a long time, so the
+// goroutine is not waited for then. The mutex must be held.
+func (p *Player) resolving() bool {
+	return p.currentTrack != nil && p.currentTrack.Stream == nil
+}
+
+// Detaches the playlist goroutine from the player so that it exits
+// without changing the player when it stops resolving, or when it
+// stops the stream of a paused track that is dropped meanwhile.
+// The goroutine does not save the state after it is detached.
+// The mutex must be held.
+func (p *Player) abandonPlaylist() {
+	p.done = nil
+}
+
 // Skips a song from the playlist
 func (p *Player) Skip() error {
-	if len(p.tracks) == 0 {
-		if !p.playing {
-			return ErrEmpty
-		} else {
-			return p.Stop()
-		}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	// A paused track is dropped and the playlist stays stopped
+	if p.paused {
+		p.paused = false
+		p.currentTrack = nil
+		p.abandonPlaylist()
+		return nil
 	}
 
 	if !p.playing {
-		<-p.tracks
+		_, err := p.nextTrack()
+		if err != nil {
+			return ErrEmpty
+		}
 		return nil
 	}
 
-	p.SongList = p.SongList[1:]
-	p.skip <- true
+	// Stopping the stream makes the playlist continue with the next track
+	// or finish if there are no more tracks.
+	p.seeking = false
+	p.skipped = true
+	if p.currentTrack != nil {
+		p.currentTrack.stopStream()
+	}
 	return nil
 }
 
-// Add the song from the URL to the playlist
-// Returns the track that is added.
-func (p *Player) AddToQueue(c *gumble.Client, url *url.URL) ([]*Track, error) {
-	redirectURL, err := getRedirectURL(url)
+// Randomizes the order of the tracks in the queue
+func (p *Player) Shuffle() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if p.queue.Len() == 0 {
+		return ErrEmpty
+	}
+
+	p.queue.Shuffle(p.rng)
+	p.pickedTracks = 0
+	return nil
+}
+
+// Returns whether the next track is picked at random
+func (p *Player) GetShuffle() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.shuffle
+}
+
+// Sets whether the next track is picked at random
+func (p *Player) SetShuffle(shuffle bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	p.shuffle = shuffle
+}
+
+// Returns the repeat mode of the player
+func (p *Player) GetRepeat() RepeatMode {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.repeat
+}
+
+// Sets the repeat mode of the player
+func (p *Player) SetRepeat(mode RepeatMode) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	p.repeat = mode
+}
+
+// Moves the current track to the position. If relative is true the position
+// is added to the current position instead. Returns the new position.
+func (p *Player) Seek(position time.Duration, relative bool) (time.Duration, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if p.currentTrack == nil {
+		return 0, ErrEmpty
+	}
+
+	if p.currentTrack.Live {
+		return 0, ErrLiveStream
+	}
+
+	if relative {
+		position += p.currentTrack.position()
+	}
+
+	if position < 0 {
+		position = 0
+	}
+
+	if position >= p.currentTrack.Duration {
+		return 0, ErrSeekRange
+	}
+
+	// A track that is not streaming starts from the new
+	// offset when it is played. Otherwise the playlist
+	// starts a new stream when the current one stops.
+	stream := p.currentTrack.Stream
+	p.currentTrack.Offset = position
+	p.currentTrack.Stream = nil
+	if stream != nil {
+		p.seeking = true
+		stream.Stop()
+	}
+
+	return position, nil
+}
+
+// Add the song from the URL to the playlist. The mode decides what
+// is added from a youtube video URL that is also part of a playlist.
+// Progress is called while the videos of a playlist are fetched and
+// it can be nil. Returns the tracks that are added. If some videos of a
+// playlist are skipped, because they cannot be played or the playlist
+// is full, the rest are added and a SkippedError is returned with them.
+func (p *Player) AddToQueue(url *url.URL, mode PlaylistMode, progress func(done, total int)) ([]*Track, error) {
+	return p.importURL(url, mode, progress, false)
+}
+
+// Adds the song from the URL to the start of the playlist so that it
+// plays after the current one. It works like AddToQueue otherwise.
+func (p *Player) PlayNext(url *url.URL, mode PlaylistMode, progress func(done, total int)) ([]*Track, error) {
+	return p.importURL(url, mode, progress, true)
+}
+
+// Gets the tracks of the URL and adds them to the end of the playlist, or
+// to its start if front is true. Sources that import playlists fetch
+// only the tracks that fit in the playlist.
+func (p *Player) importURL(u *url.URL, mode PlaylistMode, progress func(done, total int), front bool) ([]*Track, error) {
+	p.mutex.Lock()
+	space := p.queueSpace()
+	p.mutex.Unlock()
+
+	if space == 0 {
+		return nil, ErrQueueFull
+	}
+
+	opts := ImportOptions{Mode: mode, Progress: progress}
+	if space > 0 {
+		opts.Limit = space
+	}
+
+	tracks, err := p.sources.Import(u, opts)
+	if err != nil && !isPartial(tracks, err) {
+		return nil, err
+	}
+
+	// The playlist can be filled by other commands while fetching
+	added, addErr := p.addTracks(tracks, front)
+	if addErr != nil && !isPartial(added, addErr) {
+		return nil, addErr
+	}
+	return added, withSkipped(err, skippedVideos(addErr))
+}
+
+// Adds the tracks to the end of the playlist, or to its start if front
+// is true. The tracks that do not fit in the playlist are skipped and
+// reported with a SkippedError. Returns the tracks that are added.
+func (p *Player) addTracks(tracks []*Track, front bool) ([]*Track, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	space := p.queueSpace()
+	if space == 0 {
+		return nil, ErrQueueFull
+	}
+
+	var err error
+	if space > 0 && len(tracks) > space {
+		dropped := make([]SkippedVideo, 0, len(tracks)-space)
+		for _, track := range tracks[space:] {
+			dropped = append(dropped, SkippedVideo{Title: track.Title, Err: ErrQueueFull})
+		}
+		err = withSkipped(nil, dropped)
+		tracks = tracks[:space]
+	}
+
+	if front {
+		// The tracks are played next even if shuffle is on
+		p.queue.Insert(0, tracks...)
+		p.pickedTracks += len(tracks)
+	} else {
+		p.queue.Push(tracks...)
+	}
+	return tracks, err
+}
+
+// Returns the number of tracks that can be added to the queue
+// or -1 if the queue has no limit. The mutex must be held.
+func (p *Player) queueSpace() int {
+	if p.maxQueueSize <= 0 {
+		return -1
+	}
+
+	// The queue can be over the limit if the limit was lowered
+	if space := p.maxQueueSize - p.queue.Len(); space > 0 {
+		return space
+	}
+	return 0
+}
+
+// Removes the tracks from position from to position to, inclusive.
+// The positions start from 1 as in the list of next songs.
+// Returns the removed tracks.
+func (p *Player) RemoveTracks(from, to int) ([]*Track, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	removed, err := p.queue.RemoveRange(from-1, to-1)
 	if err != nil {
 		return nil, err
 	}
 
-	tracks, err := getURLTracks(redirectURL, c)
+	// The removed tracks are no longer played next
+	if from <= p.pickedTracks {
+		if to < p.pickedTracks {
+			p.pickedTracks -= to - from + 1
+		} else {
+			p.pickedTracks = from - 1
+		}
+	}
+	return removed, nil
+}
+
+// Moves the track at position from to position to.
+// The positions start from 1 as in the list of next songs.
+// Returns the moved track.
+func (p *Player) MoveTrack(from, to int) (*Track, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	track, err := p.queue.Get(from - 1)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, track := range tracks {
-		p.SongList = append(p.SongList, track.Title)
-		p.tracks <- track
+	if err := p.queue.Move(from-1, to-1); err != nil {
+		return nil, err
 	}
 
-	return tracks, nil
+	// A track moved among the tracks played next or to the start is
+	// played in order even if shuffle is on. A track moved away from
+	// them is picked at random again.
+	picked := from <= p.pickedTracks
+	if picked {
+		p.pickedTracks--
+	}
+	if to <= p.pickedTracks || to == 1 || (picked && to == p.pickedTracks+1) {
+		p.pickedTracks++
+	}
+	return track, nil
+}
+
+// Swaps the tracks at positions a and b.
+// The positions start from 1 as in the list of next songs.
+func (p *Player) SwapTracks(a, b int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if err := p.queue.Swap(a-1, b-1); err != nil {
+		return err
+	}
+
+	// A track swapped to the start is played next even if shuffle is on
+	if a != b && (a == 1 || b == 1) && p.pickedTracks == 0 {
+		p.pickedTracks = 1
+	}
+	return nil
 }
 
 // Get songs that are going to play next
 func (p *Player) GetNextSongs() (string, error) {
-	if len(p.tracks) == 0 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.queue.Len() == 0 {
 		return "", ErrEmpty
 	}
-	songlist := "<br><b>"
-	for i, songname := range p.SongList {
+	songlist := fmt.Sprintf("<br>%s<br><b>", p.modes())
+	for i, track := range p.queue.Tracks() {
 		if i == MaxNextSongs {
 			songlist += ". . . . <br>"
 			break
 		}
-		songlist += strconv.Itoa(i+1) + ": " + songname + "<br>"
+		songlist += strconv.Itoa(i+1) + ": " + track.Title + "<br>"
 	}
 	songlist += "</b>"
 	return songlist, nil
@@ -140,18 +663,18 @@ func (p *Player) GetNextSongs() (string, error) {
 
 // Searches youtube using the query argument and adds the first result to the playlist.
 // Returns the track that is added.
-func (p *Player) SearchAndAdd(c *gumble.Client, apiKey, query string) (*Track, error) {
-	rawURL, err := youtube_search.Search(query, apiKey)
+func (p *Player) SearchAndAdd(search youtube_search.Searcher, query string) (*Track, error) {
+	results, err := search(query, 1)
 	if err != nil {
 		return nil, err
 	}
 
-	parsedURL, err := url.Parse(rawURL)
+	parsedURL, err := url.Parse(results[0].URL())
 	if err != nil {
 		return nil, err
 	}
 
-	tracks, err := p.AddToQueue(c, parsedURL)
+	tracks, err := p.AddToQueue(parsedURL, PlaylistVideo, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -165,29 +688,58 @@ func (p *Player) SearchAndAdd(c *gumble.Client, apiKey, query string) (*Track, e
 
 // Clears the tracks from the playlist
 func (p *Player) ClearQueue() {
-	p.SongList = nil
-	for {
-		select {
-		case <-p.tracks:
-		default:
-			return
-		}
-	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	p.queue.Clear()
+	p.pickedTracks = 0
 }
 
 // Returns info about the current song
 func (p *Player) GetCurrentSong() (string, error) {
-	if p.currentTrack == nil || p.currentTrack.Stream == nil {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.currentTrack == nil {
 		return "", ErrEmpty
 	}
-	currentTime := formatDuration(p.currentTrack.Stream.Elapsed())
+	state := "▶"
+	if p.paused {
+		state = "⏸"
+	}
+
+	position := p.currentTrack.position()
+	currentTime := formatDuration(position)
+	if p.currentTrack.Live {
+		nowPlaying := ""
+		if title := p.currentTrack.StreamTitle(); title != "" {
+			nowPlaying = fmt.Sprintf("Now playing: %s<br>", html.EscapeString(title))
+		}
+		return fmt.Sprintf("<h4>%s %s LIVE</h4>%s%s<br>%v", currentTime, state, nowPlaying, p.modes(), p.currentTrack), nil
+	}
+
 	totalTime := formatDuration(p.currentTrack.Duration)
-	progress := getProgressBar(p.currentTrack.Duration, p.currentTrack.Stream.Elapsed())
-	return fmt.Sprintf("<h4>%s ▶ %s %s</h4>%v", currentTime, progress, totalTime, p.currentTrack), nil
+	progress := getProgressBar(p.currentTrack.Duration, position)
+	return fmt.Sprintf("<h4>%s %s %s %s</h4>%s<br>%v", currentTime, state, progress, totalTime, p.modes(), p.currentTrack), nil
+}
+
+// Returns the repeat and shuffle modes for displaying.
+// The mutex must be held.
+func (p *Player) modes() string {
+	shuffle := "off"
+	if p.shuffle {
+		shuffle = "on"
+	}
+
+	return fmt.Sprintf("Repeat: %v, Shuffle: %s", p.repeat, shuffle)
 }
 
 // Returns the current volume in float (Range: 0 - 1)
 func (p *Player) GetVolume() float32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	return p.volume
 }
 
@@ -198,64 +750,261 @@ func (p *Player) SetVolume(vol int) error {
 		return ErrVolumeRange
 	}
 
-	p.volume = float32(vol) / 100
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
 
-	p.streamMutex.Lock()
+	p.volume = float32(vol) / 100
 	if p.currentTrack != nil && p.currentTrack.Stream != nil {
 		p.currentTrack.Stream.Volume = p.volume
 	}
-	p.streamMutex.Unlock()
 
 	return nil
 }
 
-// Start playing song from the playlist
-// until it receives from the stop channel
-func (p *Player) startPlaylist(c *gumble.Client) {
-	stop := false
+// Start playing songs from the queue until the queue is empty
+// or the player is stopped or paused. If there is a current track
+// it is played first. Closes done on exit.
+func (p *Player) startPlaylist(done chan bool) {
+	defer close(done)
 
-	for p.currentTrack = range p.tracks {
-		finished := make(chan bool)
+	for {
+		p.mutex.Lock()
+		if !p.playing {
+			p.mutex.Unlock()
+			return
+		}
 
-		p.streamMutex.Lock()
-		p.currentTrack.Stream.Volume = p.volume
-		p.streamMutex.Unlock()
-		go playStream(p.currentTrack.Stream, finished)
-		select {
-		case <-p.stop:
-			p.streamMutex.Lock()
-			p.currentTrack.Stream.Stop()
-			p.streamMutex.Unlock()
-			stop = true
-		case <-p.skip:
-			p.streamMutex.Lock()
-			p.currentTrack.Stream.Stop()
-			p.streamMutex.Unlock()
-		case <-finished:
-			if len(p.tracks) == 0 {
+		if p.currentTrack == nil {
+			track, err := p.nextTrack()
+			if err != nil {
 				p.playing = false
-				stop = true
+				p.saveState()
+				p.mutex.Unlock()
+				return
+			}
+			p.currentTrack = track
+			p.saveState()
+		}
+		track := p.currentTrack
+		p.mutex.Unlock()
+
+		// Resolving can take a while so the mutex is not held
+		// and the player can be stopped or skipped meanwhile.
+		err := track.resolve()
+
+		p.mutex.Lock()
+		// The player does not wait for a goroutine that is resolving
+		// when it stops. A new one can have started meanwhile.
+		if p.done != done {
+			p.mutex.Unlock()
+			return
+		}
+
+		if err != nil {
+			p.skipped = false
+			p.trackFailed(err)
+			p.saveState()
+			p.mutex.Unlock()
+			continue
+		}
+
+		var stream *gumbleffmpeg.Stream
+		var playErr error
+		if p.playing && !p.skipped && !p.previous {
+			stream, playErr = track.newStream(p.client)
+		}
+
+		if stream != nil {
+			stream.Volume = p.volume
+			finished := make(chan error, 1)
+			playStream(stream, finished)
+			prefetch := p.startPrefetchTimer(track)
+			p.mutex.Unlock()
+
+			playErr = <-finished
+			if prefetch != nil {
+				prefetch.Stop()
+			}
+
+			p.mutex.Lock()
+			if p.done != done {
+				p.mutex.Unlock()
+				return
 			}
 		}
+		p.finishTrack(stream, playErr)
+		p.saveState()
+		p.mutex.Unlock()
+	}
+}
+
+// Decides what happens to the current track after its stream stops.
+// The stream is nil if the track was not played and playErr is
+// the error from starting the stream. The mutex must be held.
+func (p *Player) finishTrack(stream *gumbleffmpeg.Stream, playErr error) {
+	track := p.currentTrack
+	if track.Stream == stream {
+		track.Stream = nil
+	}
+
+	if p.seeking {
+		p.seeking = false
+		return
+	}
+
+	if p.paused {
+		// Streams cannot be replayed so the next one continues
+		// from the same position. Live streams continue from
+		// what is playing when they are resumed.
+		if stream != nil && !track.Live {
+			track.Offset = stream.Offset + stream.Elapsed()
+		}
+		return
+	}
 
+	if p.previous {
+		p.previous = false
+		p.skipped = false
+		track.Offset = 0
+		if err := p.queue.Insert(1, track); err != nil {
+			p.queue.Push(track)
+		} else if p.pickedTracks > 0 {
+			// The track is played after the previous one
+			p.pickedTracks++
+		}
 		p.currentTrack = nil
+		return
+	}
 
-		if stop {
-			break
+	// A stream that stopped by itself without playing anything
+	// usually means that the stream URL has expired.
+	if playErr == nil && stream != nil && stream.Elapsed() == 0 && p.playing && !p.skipped {
+		playErr = ErrStreamFailed
+	}
+
+	if playErr != nil && !track.reresolved {
+		log.Printf("Could not play %s, resolving it again: %v\n", track.PublicURL, playErr)
+		track.expire()
+		track.reresolved = true
+		return
+	}
+
+	track.Offset = 0
+	track.reresolved = false
+	if playErr != nil {
+		p.trackFailed(playErr)
+		return
+	}
+
+	if stream != nil {
+		p.failures = 0
+	}
+
+	if p.playing && p.repeat == RepeatOne && !p.skipped {
+		return
+	}
+
+	if p.playing && p.repeat == RepeatAll {
+		p.queue.Push(track)
+	}
+
+	if stream != nil {
+		p.addToHistory(track)
+	}
+	p.skipped = false
+	p.currentTrack = nil
+}
+
+// Announces that the current track failed and drops it. Stops
+// the playlist if too many tracks failed in a row. The mutex must be held.
+func (p *Player) trackFailed(err error) {
+	log.Printf("Could not play %s: %v\n", p.currentTrack.PublicURL, err)
+	p.client.Self.Channel.Send(fmt.Sprintf("Could not play %s, skipping it", p.currentTrack.Title), false)
+	p.currentTrack = nil
+
+	p.failures++
+	if p.maxFailures > 0 && p.failures >= p.maxFailures {
+		p.client.Self.Channel.Send(fmt.Sprintf("Stopping the playlist after %d songs failed in a row", p.failures), false)
+		p.failures = 0
+		p.playing = false
+	}
+}
+
+// Removes and returns the track to play next from the queue.
+// The mutex must be held.
+func (p *Player) nextTrack() (*Track, error) {
+	if _, err := p.peekNextTrack(); err != nil {
+		return nil, err
+	}
+
+	if p.pickedTracks > 0 {
+		p.pickedTracks--
+	}
+	return p.queue.Pop()
+}
+
+// Returns the track to play next without removing it from the queue.
+// If shuffle is on a random track is picked and moved to the start
+// of the queue so that it is the one played next. The mutex must be held.
+func (p *Player) peekNextTrack() (*Track, error) {
+	if p.shuffle && p.queue.Len() > 1 && p.pickedTracks == 0 {
+		if err := p.queue.Move(p.rng.Intn(p.queue.Len()), 0); err != nil {
+			return nil, err
 		}
+		p.pickedTracks = 1
 	}
+
+	return p.queue.Get(0)
 }
 
-// Receives an audio stream and a channel. It plays the stream
-// and send a message to the channel when it is finished.
-func playStream(s *gumbleffmpeg.Stream, finished chan bool) {
-	err := s.Play()
+// Starts a timer that resolves the next track shortly before the
+// current track ends, so that the next one starts without delay.
+// Returns nil if the duration of the track is unknown. The mutex must be held.
+func (p *Player) startPrefetchTimer(t *Track) *time.Timer {
+	if t.Duration == 0 {
+		return nil
+	}
+
+	remaining := t.Duration - t.Offset - p.prefetchTime
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return time.AfterFunc(remaining, p.prefetch)
+}
+
+// Resolves the next track of the queue
+func (p *Player) prefetch() {
+	p.mutex.Lock()
+	if !p.playing {
+		p.mutex.Unlock()
+		return
+	}
+
+	next, err := p.peekNextTrack()
+	p.mutex.Unlock()
 	if err != nil {
-		log.Fatalln(err)
+		return
+	}
+
+	if err := next.resolve(); err != nil {
+		log.Printf("Could not prefetch %s: %v\n", next.PublicURL, err)
+	}
+}
+
+// Receives an audio stream and a channel. It starts the stream and
+// sends to the channel nil when it is finished or the error if it cannot start.
+func playStream(s *gumbleffmpeg.Stream, finished chan error) {
+	if err := s.Play(); err != nil {
+		finished <- err
+		return
 	}
 
-	s.Wait()
-	finished <- true
+	go func() {
+		s.Wait()
+		finished <- nil
+	}()
 }
 
 // Creates and returns a string with the format "hh:mm:ss"
@@ -271,6 +1020,10 @@ func formatDuration(d time.Duration) string {
 
 // Creates and returns a progress bar with unicode characters
 func getProgressBar(total, elapsed time.Duration) string {
+	if total <= 0 {
+		return strings.Repeat("➖", 10)
+	}
+
 	//returns value 0 - 1 (0 = just started, 1 = finished)
 	percentage_played := 1 - (total.Seconds()-elapsed.Seconds())/total.Seconds()
 	track_lines := ""
@@ -286,52 +1039,3 @@ func getProgressBar(total, elapsed time.Duration) string {
 	}
 	return track_lines
 }
-
-// Receives a URL and returns an audio stream or an error
-func getURLTracks(u *url.URL, client *gumble.Client) ([]*Track, error) {
-	switch u.Host {
-	case "www.youtube.com":
-		return getYoutubeTracks(client, u)
-	default:
-		return nil, ErrIncorrectURL
-	}
-}
-
-// Receives a youtube video URL and returns
-// the streaming URL or an error
-func getYoutubeTracks(c *gumble.Client, u *url.URL) ([]*Track, error) {
-	client := new(youtube.Client)
-	switch u.Path {
-	case "/watch":
-		video, err := client.GetVideo(u.String())
-		if err != nil {
-			return nil, err
-		}
-
-		track, err := YoutubeVideoToTrack(c, client, video)
-		return []*Track{track}, err
-	case "/playlist":
-		playlist, err := client.GetPlaylist(u.String())
-		if err != nil {
-			return nil, err
-		}
-
-		return YoutubePlaylistToTracks(c, client, playlist)
-	default:
-		return nil, ErrIncorrectURL
-	}
-}
-
-// Receives a url and returns the final redirection url
-func getRedirectURL(url *url.URL) (*url.URL, error) {
-	resp, err := http.Head(url.String())
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, ErrIncorrectURL
-	}
-
-	return resp.Request.URL, nil
-}
@@ -0,0 +1,333 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestShuffleNextTrack(t *testing.T) {
+	tracks := testTracks(6)
+	p := New(Config{})
+	p.SetSeed(7)
+	p.SetShuffle(true)
+	p.queue.Push(tracks...)
+
+	// Each track is picked like the player does with the same seed
+	remaining := NewQueue()
+	remaining.Push(tracks...)
+	r := rand.New(rand.NewSource(7))
+	for remaining.Len() > 0 {
+		i := 0
+		if remaining.Len() > 1 {
+			i = r.Intn(remaining.Len())
+		}
+		want, _ := remaining.Remove(i)
+
+		p.mutex.Lock()
+		peeked, err := p.peekNextTrack()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The picked track stays the next one until it is played
+		again, _ := p.peekNextTrack()
+		next, err := p.nextTrack()
+		p.mutex.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if peeked != want || again != want || next != want {
+			t.Fatalf("got %s, %s and %s, want %s", peeked.Title, again.Title, next.Title, want.Title)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, err := p.nextTrack(); err == nil {
+		t.Fatal("got a track from an empty queue")
+	}
+}
+
+func TestShuffleOffKeepsOrder(t *testing.T) {
+	tracks := testTracks(4)
+	p := New(Config{})
+	p.SetSeed(7)
+	p.queue.Push(tracks...)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, want := range tracks {
+		next, err := p.nextTrack()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next != want {
+			t.Fatalf("got %s, want %s", next.Title, want.Title)
+		}
+	}
+}
+
+func TestShuffleSeed(t *testing.T) {
+	orders := make([]string, 2)
+	for i := range orders {
+		p := New(Config{})
+		p.SetSeed(3)
+		p.queue.Push(testTracks(8)...)
+		if err := p.Shuffle(); err != nil {
+			t.Fatal(err)
+		}
+		orders[i] = fmt.Sprint(titles(p.queue.Tracks()))
+	}
+
+	if orders[0] != orders[1] {
+		t.Fatalf("the same seed gave %s and %s", orders[0], orders[1])
+	}
+}
+
+// Returns a track that resolves only after release is closed.
+// It sends to resolving when it starts resolving.
+func blockingTrack(resolving chan<- bool, release <-chan bool) *Track {
+	return &Track{
+		Title: "slow",
+		resolveURL: func() (string, error) {
+			resolving <- true
+			<-release
+			return "", errors.New("resolved too late")
+		},
+	}
+}
+
+// Calls f and fails if it does not return in time
+func returnsInTime(t *testing.T, name string, f func() error) {
+	t.Helper()
+
+	result := make(chan error, 1)
+	go func() { result <- f() }()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s waited for the track to resolve", name)
+	}
+}
+
+func TestStopWhileResolving(t *testing.T) {
+	resolving, release := make(chan bool), make(chan bool)
+	p := New(Config{})
+	p.queue.Push(blockingTrack(resolving, release))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	<-resolving
+	p.mutex.Lock()
+	done := p.done
+	p.mutex.Unlock()
+
+	returnsInTime(t, "Stop", p.Stop)
+	close(release)
+	<-done
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.playing || p.currentTrack != nil {
+		t.Fatalf("got playing %v and track %v after stopping", p.playing, p.currentTrack)
+	}
+}
+
+func TestPauseWhileResolving(t *testing.T) {
+	resolving, release := make(chan bool), make(chan bool)
+	track := blockingTrack(resolving, release)
+	p := New(Config{})
+	p.queue.Push(track)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	<-resolving
+	p.mutex.Lock()
+	done := p.done
+	p.mutex.Unlock()
+
+	returnsInTime(t, "Pause", p.Pause)
+	close(release)
+	<-done
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.paused || p.currentTrack != track {
+		t.Fatal("the paused track was not kept")
+	}
+}
+
+func TestAddToQueueLimit(t *testing.T) {
+	importer := &fakeImporter{fakeSource: fakeSource{host: "import.test"}, videos: 10}
+	p := New(Config{MaxQueueSize: 5})
+	p.sources = NewRegistry(importer)
+	p.queue.Push(testTracks(2)...)
+
+	var progress []int
+	u, _ := url.Parse("http://import.test/playlist")
+	tracks, err := p.AddToQueue(u, PlaylistFromVideo, func(done, total int) {
+		progress = append(progress, done, total)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the tracks that fit are imported
+	if importer.opts.Limit != 3 || importer.opts.Mode != PlaylistFromVideo || len(tracks) != 3 {
+		t.Fatalf("got %d tracks and options %+v", len(tracks), importer.opts)
+	}
+
+	if fmt.Sprint(progress) != "[3 10]" {
+		t.Fatalf("got progress %v", progress)
+	}
+
+	if _, err := p.AddToQueue(u, PlaylistAll, nil); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got error %v, want %v", err, ErrQueueFull)
+	}
+}
+
+// Returns a player with shuffle on, a fixed seed and n tracks in the queue
+func shufflePlayer(n int) (*Player, []*Track) {
+	tracks := testTracks(n)
+	p := New(Config{})
+	p.SetSeed(7)
+	p.SetShuffle(true)
+	p.queue.Push(tracks...)
+	return p, tracks
+}
+
+// Returns the track that the player plays next
+func nextTrack(t *testing.T, p *Player) *Track {
+	t.Helper()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	track, err := p.nextTrack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return track
+}
+
+// Picks the next track at random without playing it
+func peek(p *Player) *Track {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	track, _ := p.peekNextTrack()
+	return track
+}
+
+func TestShufflePlayNext(t *testing.T) {
+	p, _ := shufflePlayer(20)
+	peek(p)
+
+	next := &Track{Title: "next"}
+	if _, err := p.addTracks([]*Track{next}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := nextTrack(t, p); got != next {
+		t.Fatalf("got %s, want the track added to the front", got.Title)
+	}
+}
+
+func TestShuffleMoveAndSwap(t *testing.T) {
+	p, tracks := shufflePlayer(20)
+	peek(p)
+	moved, err := p.MoveTrack(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := nextTrack(t, p); got != moved {
+		t.Fatalf("got %s, want the track moved to the start %s", got.Title, moved.Title)
+	}
+
+	peek(p)
+	swapped, _ := p.queue.Get(4)
+	if err := p.SwapTracks(5, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := nextTrack(t, p); got != swapped {
+		t.Fatalf("got %s, want the track swapped to the start %s", got.Title, swapped.Title)
+	}
+
+	if p.queue.Len() != len(tracks)-2 {
+		t.Fatalf("got %d tracks", p.queue.Len())
+	}
+}
+
+func TestShuffleRemoveNext(t *testing.T) {
+	for _, change := range []func(p *Player) error{
+		func(p *Player) error { _, err := p.RemoveTracks(1, 1); return err },
+		func(p *Player) error { _, err := p.MoveTrack(1, 20); return err },
+	} {
+		p, _ := shufflePlayer(20)
+		picked := peek(p)
+		if err := change(p); err != nil {
+			t.Fatal(err)
+		}
+
+		// The next track is picked at random again, like it would be
+		// without the change since the generator has the same seed
+		r := rand.New(rand.NewSource(7))
+		r.Intn(20)
+		want, _ := p.queue.Get(r.Intn(p.queue.Len()))
+		if got := nextTrack(t, p); got != want || got == picked {
+			t.Fatalf("got %s, want the random track %s", got.Title, want.Title)
+		}
+	}
+}
+
+func TestShufflePrevious(t *testing.T) {
+	resolving, release := make(chan bool), make(chan bool)
+	previous := blockingTrack(resolving, release)
+	p, _ := shufflePlayer(20)
+	p.sources = NewRegistry(&fakeSource{
+		host: "history.test",
+		tracks: func(u *url.URL) ([]*Track, error) {
+			return []*Track{previous}, nil
+		},
+	})
+
+	current := &Track{Title: "current"}
+	p.history = []*Track{{PublicURL: "http://history.test/previous"}}
+	p.currentTrack = current
+	p.paused = true
+
+	if _, err := p.Previous(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous track is played first and the
+	// paused one after it even though shuffle is on
+	<-resolving
+	p.mutex.Lock()
+	done := p.done
+	playing := p.currentTrack
+	p.mutex.Unlock()
+	if playing != previous {
+		t.Fatalf("got %v, want the previous track", playing)
+	}
+
+	if got := peek(p); got != current {
+		t.Fatalf("got %s, want the paused track", got.Title)
+	}
+
+	returnsInTime(t, "Stop", p.Stop)
+	close(release)
+	<-done
+}
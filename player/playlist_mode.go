@@ -0,0 +1,66 @@
+package player
+
+import "errors"
+
+var ErrPlaylistMode = errors.New("unknown playlist mode")
+
+// What is added from a youtube video URL that is also part of a playlist
+type PlaylistMode int
+
+const (
+	// Only the video is added
+	PlaylistVideo PlaylistMode = iota
+	// The whole playlist is added
+	PlaylistAll
+	// The playlist is added starting from the video
+	PlaylistFromVideo
+)
+
+// The options for importing the tracks of a URL
+type ImportOptions struct {
+	// What is added from a youtube video URL that is also part of a playlist
+	Mode PlaylistMode
+	// The maximum number of videos of a playlist that are fetched.
+	// The rest are skipped with ErrQueueFull. Zero means no limit.
+	Limit int
+	// Called with the number of videos of a playlist that are fetched
+	// and their total. It can be nil and it must be safe for concurrent use.
+	Progress func(done, total int)
+}
+
+// Returns the name of the playlist mode
+func (m PlaylistMode) String() string {
+	switch m {
+	case PlaylistAll:
+		return "all"
+	case PlaylistFromVideo:
+		return "from"
+	default:
+		return "video"
+	}
+}
+
+// Sets the mode from its name so that it can be read from the config
+func (m *PlaylistMode) UnmarshalText(text []byte) error {
+	mode, err := ParsePlaylistMode(string(text))
+	if err != nil {
+		return err
+	}
+
+	*m = mode
+	return nil
+}
+
+// Receives the name of a playlist mode and returns the mode
+func ParsePlaylistMode(name string) (PlaylistMode, error) {
+	switch name {
+	case "video":
+		return PlaylistVideo, nil
+	case "all":
+		return PlaylistAll, nil
+	case "from":
+		return PlaylistFromVideo, nil
+	default:
+		return PlaylistVideo, ErrPlaylistMode
+	}
+}
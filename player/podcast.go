@@ -0,0 +1,403 @@
+package player
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The number of the most recent episodes of a podcast that are listed
+const MaxEpisodes = 10
+
+// The maximum size of a podcast feed that is read
+const maxFeedSize = 10 * 1024 * 1024
+
+// How long downloading a podcast feed can take
+const podcastRequestTimeout = 30 * time.Second
+
+// The namespace of the podcast elements of iTunes
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+var (
+	ErrNoEpisodes = errors.New("the feed has no episodes")
+	ErrFeed       = errors.New("could not read the podcast feed")
+)
+
+var podcastClient = &http.Client{Timeout: podcastRequestTimeout}
+
+// The formats of the publication dates of the episodes
+var episodeDateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+}
+
+// A podcast read from an RSS or Atom feed
+type Podcast struct {
+	Title      string
+	ArtworkURL string
+	// The episodes, the most recent first
+	Episodes []Episode
+}
+
+// An episode of a podcast
+type Episode struct {
+	Title     string
+	AudioURL  string
+	Duration  time.Duration
+	Published time.Time
+}
+
+// The elements of an RSS feed that are read
+type rssFeed struct {
+	Channel struct {
+		Title  []feedText `xml:"title"`
+		Images []struct {
+			XMLName xml.Name
+			URL     string `xml:"url"`
+			Href    string `xml:"href,attr"`
+		} `xml:"image"`
+		Items []struct {
+			Title     []feedText `xml:"title"`
+			PubDate   string     `xml:"pubDate"`
+			Duration  string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Enclosure struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// The elements of an Atom feed that are read
+type atomFeed struct {
+	Title   []feedText `xml:"title"`
+	Logo    string     `xml:"logo"`
+	Icon    string     `xml:"icon"`
+	Entries []struct {
+		Title     []feedText `xml:"title"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+		Duration  string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+		Links     []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// The text of an element and its namespace. Feeds repeat elements
+// like the title in the iTunes namespace and without a namespace
+// they cannot be told apart.
+type feedText struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// Returns the text of the first element that is not in the
+// iTunes namespace, or of the first element if all of them are
+func plainText(elements []feedText) string {
+	for _, e := range elements {
+		if e.XMLName.Space != itunesNamespace {
+			return strings.TrimSpace(e.Text)
+		}
+	}
+
+	if len(elements) > 0 {
+		return strings.TrimSpace(elements[0].Text)
+	}
+	return ""
+}
+
+// Downloads and parses the podcast feed of the URL
+func FetchPodcast(feedURL *url.URL) (*Podcast, error) {
+	resp, err := podcastClient.Get(feedURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrFeed
+	}
+
+	return ParsePodcast(io.LimitReader(resp.Body, maxFeedSize), resp.Request.URL)
+}
+
+// Parses an RSS or an Atom podcast feed. Episodes without audio
+// are skipped and relative URLs are resolved against base.
+func ParsePodcast(r io.Reader, base *url.URL) (*Podcast, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFeed, err)
+	}
+
+	var podcast *Podcast
+	switch root.XMLName.Local {
+	case "rss":
+		podcast, err = parseRSS(data)
+	case "feed":
+		podcast, err = parseAtom(data)
+	default:
+		return nil, ErrFeed
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFeed, err)
+	}
+
+	podcast.ArtworkURL = resolveReference(base, podcast.ArtworkURL)
+	episodes := make([]Episode, 0, len(podcast.Episodes))
+	for _, e := range podcast.Episodes {
+		e.AudioURL = resolveReference(base, e.AudioURL)
+		if e.AudioURL != "" {
+			episodes = append(episodes, e)
+		}
+	}
+
+	// Feeds are usually sorted already but not always
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].Published.After(episodes[j].Published)
+	})
+
+	if len(episodes) == 0 {
+		return nil, ErrNoEpisodes
+	}
+
+	podcast.Episodes = episodes
+	return podcast, nil
+}
+
+// Reads the podcast of an RSS feed
+func parseRSS(data []byte) (*Podcast, error) {
+	feed := new(rssFeed)
+	if err := xml.Unmarshal(data, feed); err != nil {
+		return nil, err
+	}
+
+	podcast := &Podcast{
+		Title:    plainText(feed.Channel.Title),
+		Episodes: make([]Episode, 0, len(feed.Channel.Items)),
+	}
+
+	// The image of the RSS channel is preferred since
+	// the iTunes one is usually too large to show
+	for _, image := range feed.Channel.Images {
+		if image.XMLName.Space != itunesNamespace && image.URL != "" {
+			podcast.ArtworkURL = strings.TrimSpace(image.URL)
+			break
+		}
+
+		if image.XMLName.Space == itunesNamespace && podcast.ArtworkURL == "" {
+			podcast.ArtworkURL = strings.TrimSpace(image.Href)
+		}
+	}
+
+	for _, item := range feed.Channel.Items {
+		if item.Enclosure.Type != "" && !isAudioContentType(item.Enclosure.Type) {
+			continue
+		}
+
+		podcast.Episodes = append(podcast.Episodes, Episode{
+			Title:     plainText(item.Title),
+			AudioURL:  strings.TrimSpace(item.Enclosure.URL),
+			Duration:  parseEpisodeDuration(item.Duration),
+			Published: parseEpisodeDate(item.PubDate),
+		})
+	}
+
+	return podcast, nil
+}
+
+// Reads the podcast of an Atom feed. The audio of an
+// entry is its link with the enclosure relation.
+func parseAtom(data []byte) (*Podcast, error) {
+	feed := new(atomFeed)
+	if err := xml.Unmarshal(data, feed); err != nil {
+		return nil, err
+	}
+
+	podcast := &Podcast{
+		Title:      plainText(feed.Title),
+		ArtworkURL: feed.Logo,
+		Episodes:   make([]Episode, 0, len(feed.Entries)),
+	}
+
+	if podcast.ArtworkURL == "" {
+		podcast.ArtworkURL = feed.Icon
+	}
+
+	for _, entry := range feed.Entries {
+		audioURL := ""
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" && (link.Type == "" || isAudioContentType(link.Type)) {
+				audioURL = strings.TrimSpace(link.Href)
+				break
+			}
+		}
+
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+
+		podcast.Episodes = append(podcast.Episodes, Episode{
+			Title:     plainText(entry.Title),
+			AudioURL:  audioURL,
+			Duration:  parseEpisodeDuration(entry.Duration),
+			Published: parseEpisodeDate(published),
+		})
+	}
+
+	return podcast, nil
+}
+
+// Parses the itunes:duration of an episode, which is either
+// a number of seconds or of the form "H:MM:SS" or "MM:SS".
+// Returns 0 if the duration is unknown.
+func parseEpisodeDuration(s string) time.Duration {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Parses the publication date of an episode.
+// Returns the zero time if the date is unknown.
+func parseEpisodeDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, format := range episodeDateFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Resolves the reference against base.
+// Returns an empty string if it is not an HTTP URL.
+func resolveReference(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.String()
+}
+
+// Returns the tracks of the episodes of the podcast.
+// The artwork of the show is the thumbnail of every track.
+func (pc *Podcast) tracks(episodes []Episode) []*Track {
+	var artwork *Thumbnail
+	if pc.ArtworkURL != "" {
+		thumbnail, err := NewThumbnail(pc.ArtworkURL)
+		if err != nil {
+			log.Printf("Could not download the artwork of %s: %v\n", pc.Title, err)
+		} else if len(thumbnail.Data) <= maxArtworkSize {
+			artwork = thumbnail
+		}
+	}
+
+	tracks := make([]*Track, len(episodes))
+	for i, e := range episodes {
+		audioURL := e.AudioURL
+		tracks[i] = &Track{
+			Title:      e.Title,
+			Artist:     pc.Title,
+			Duration:   e.Duration,
+			PublicURL:  audioURL,
+			StreamURL:  audioURL,
+			Thumbnail:  artwork,
+			resolveURL: func() (string, error) { return audioURL, nil },
+		}
+	}
+
+	return tracks
+}
+
+// Returns the most recent episodes of the podcast of the feed for displaying
+func (p *Player) GetEpisodes(feedURL *url.URL) (string, error) {
+	podcast, err := FetchPodcast(feedURL)
+	if err != nil {
+		return "", err
+	}
+
+	list := fmt.Sprintf("<br><b>%s</b><br>", html.EscapeString(podcast.Title))
+	for i, e := range podcast.Episodes {
+		if i == MaxEpisodes {
+			list += ". . . . <br>"
+			break
+		}
+
+		details := make([]string, 0, 2)
+		if !e.Published.IsZero() {
+			details = append(details, e.Published.Format("2 Jan 2006"))
+		}
+		if e.Duration > 0 {
+			details = append(details, formatDuration(e.Duration))
+		}
+
+		list += fmt.Sprintf("%d: %s", i+1, html.EscapeString(e.Title))
+		if len(details) > 0 {
+			list += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+		}
+		list += "<br>"
+	}
+	return list, nil
+}
+
+// Adds the episode at position n of the podcast of the feed to the
+// playlist. The positions start from 1, the most recent episode.
+// Returns the track that is added.
+func (p *Player) AddEpisode(feedURL *url.URL, n int) (*Track, error) {
+	podcast, err := FetchPodcast(feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 1 || n > len(podcast.Episodes) {
+		return nil, ErrIndexRange
+	}
+
+	track := podcast.tracks(podcast.Episodes[n-1 : n])[0]
+	if _, err := p.addTracks([]*Track{track}, false); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
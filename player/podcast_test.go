@@ -0,0 +1,157 @@
+package player
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Parses the feed of the test data with the base URL
+func parseTestFeed(t *testing.T, name string, base string) *Podcast {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	u, _ := url.Parse(base)
+	podcast, err := ParsePodcast(f, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return podcast
+}
+
+// Checks the episodes against the wanted titles, URLs and durations
+func checkEpisodes(t *testing.T, episodes []Episode, want []Episode) {
+	t.Helper()
+
+	if len(episodes) != len(want) {
+		t.Fatalf("got %d episodes, want %d: %+v", len(episodes), len(want), episodes)
+	}
+
+	for i, e := range episodes {
+		if e.Title != want[i].Title || e.AudioURL != want[i].AudioURL || e.Duration != want[i].Duration {
+			t.Errorf("episode %d: got %s %s %v, want %s %s %v", i, e.Title, e.AudioURL, e.Duration, want[i].Title, want[i].AudioURL, want[i].Duration)
+		}
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	podcast := parseTestFeed(t, "podcast.rss", "https://example.com/feeds/show.rss")
+
+	// The titles without a namespace are preferred over the iTunes ones
+	if podcast.Title != "The Test Show" {
+		t.Errorf("got title %q", podcast.Title)
+	}
+
+	// The small channel image is preferred over the iTunes one
+	if podcast.ArtworkURL != "https://example.com/images/small.jpg" {
+		t.Errorf("got artwork %q", podcast.ArtworkURL)
+	}
+
+	// The newest episode is first and the ones without audio are skipped
+	checkEpisodes(t, podcast.Episodes, []Episode{
+		{Title: "Hours", AudioURL: "https://example.com/feeds/episodes/hours.mp3", Duration: time.Hour + 2*time.Minute + 3*time.Second},
+		{Title: "Minutes", AudioURL: "https://example.com/minutes.ogg", Duration: 12*time.Minute + 34*time.Second},
+		{Title: "Seconds", AudioURL: "https://cdn.example.com/seconds.mp3", Duration: 754 * time.Second},
+	})
+
+	if want := time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC); !podcast.Episodes[0].Published.Equal(want) {
+		t.Errorf("got date %v, want %v", podcast.Episodes[0].Published, want)
+	}
+}
+
+func TestParseRSSItunesImage(t *testing.T) {
+	podcast := parseTestFeed(t, "podcast-itunes-image.rss", "https://example.com/feed")
+	if podcast.ArtworkURL != "https://cdn.example.com/itunes.jpg" {
+		t.Errorf("got artwork %q, want the iTunes image", podcast.ArtworkURL)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	podcast := parseTestFeed(t, "podcast.atom", "https://example.com/feeds/show.atom")
+
+	if podcast.Title != "The Atom Show" {
+		t.Errorf("got title %q", podcast.Title)
+	}
+
+	// The logo is preferred over the icon
+	if podcast.ArtworkURL != "https://example.com/logo.png" {
+		t.Errorf("got artwork %q", podcast.ArtworkURL)
+	}
+
+	// The publication date is preferred over the update date
+	checkEpisodes(t, podcast.Episodes, []Episode{
+		{Title: "New", AudioURL: "https://cdn.example.com/new.ogg", Duration: 45 * time.Minute},
+		{Title: "Old", AudioURL: "https://example.com/feeds/old.mp3", Duration: 90 * time.Second},
+	})
+}
+
+func TestParsePodcastErrors(t *testing.T) {
+	base, _ := url.Parse("https://example.com/feed")
+	tests := map[string]error{
+		"<html><body></body></html>": ErrFeed,
+		"not xml":                    ErrFeed,
+		"<rss><channel><title>Empty</title></channel></rss>": ErrNoEpisodes,
+	}
+
+	for feed, want := range tests {
+		if _, err := ParsePodcast(strings.NewReader(feed), base); !errors.Is(err, want) {
+			t.Errorf("%q: got error %v, want %v", feed, err, want)
+		}
+	}
+}
+
+func TestParseEpisodeDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"59":       59 * time.Second,
+		"754":      754 * time.Second,
+		"12:34":    12*time.Minute + 34*time.Second,
+		"1:02:03":  time.Hour + 2*time.Minute + 3*time.Second,
+		" 01:00 ":  time.Minute,
+		"1.5":      1500 * time.Millisecond,
+		"":         0,
+		"1:2:3:4":  0,
+		"abc":      0,
+		"-5":       0,
+		"12:xx:00": 0,
+	}
+
+	for s, want := range tests {
+		if got := parseEpisodeDuration(s); got != want {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestGetEpisodesEscapesTitles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel>
+			<title>Show &lt;b&gt;</title>
+			<item>
+				<title>Episode &lt;script&gt;</title>
+				<enclosure url="/episode.mp3" type="audio/mpeg" length="1"/>
+			</item>
+		</channel></rss>`))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	list, err := New(Config{}).GetEpisodes(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(list, "Show &lt;b&gt;") || !strings.Contains(list, "1: Episode &lt;script&gt;") {
+		t.Fatalf("the titles were not escaped: %s", list)
+	}
+}
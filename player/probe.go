@@ -0,0 +1,101 @@
+package player
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The command used to read the metadata of audio files
+const probeCommand = "ffprobe"
+
+// How long ffprobe can take to read the metadata of an input.
+// Reading a URL can otherwise hang on a server that stops responding.
+const probeTimeout = 30 * time.Second
+
+// The metadata of an audio file
+type probeInfo struct {
+	Title  string
+	Artist string
+	Album  string
+	// The track number tag like "3" or "3/12"
+	TrackNumber string
+	Duration    time.Duration
+}
+
+type probeTags map[string]string
+
+type probeOutput struct {
+	Format struct {
+		Duration string    `json:"duration"`
+		Tags     probeTags `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		Tags probeTags `json:"tags"`
+	} `json:"streams"`
+}
+
+// Returns the value of the tag ignoring the case of its name
+func (t probeTags) get(name string) string {
+	for key, value := range t {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Reads the metadata of the input, which can be a path or a URL, with ffprobe
+func probe(input string) (*probeInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, probeCommand, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", input).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProbeOutput(bytes.NewReader(out))
+}
+
+// Parses the JSON output of ffprobe and returns the metadata
+func parseProbeOutput(r io.Reader) (*probeInfo, error) {
+	output := new(probeOutput)
+	if err := json.NewDecoder(r).Decode(output); err != nil {
+		return nil, err
+	}
+
+	// Containers like ogg keep the tags in the audio stream
+	tags := []probeTags{output.Format.Tags}
+	for _, stream := range output.Streams {
+		tags = append(tags, stream.Tags)
+	}
+
+	info := new(probeInfo)
+	for _, t := range tags {
+		if info.Title == "" {
+			info.Title = t.get("title")
+		}
+		if info.Artist == "" {
+			info.Artist = t.get("artist")
+		}
+		if info.Album == "" {
+			info.Album = t.get("album")
+		}
+		if info.TrackNumber == "" {
+			info.TrackNumber = t.get("track")
+		}
+	}
+
+	if seconds, err := strconv.ParseFloat(output.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return info, nil
+}
@@ -0,0 +1,124 @@
+package player
+
+import (
+	"errors"
+	"math/rand"
+)
+
+var ErrIndexRange = errors.New("index out of range")
+
+// An ordered list of tracks waiting to be played.
+// The queue is not safe for concurrent use, the caller must guard it.
+type Queue struct {
+	tracks []*Track
+}
+
+// Creates and returns an empty queue
+func NewQueue() *Queue {
+	return &Queue{tracks: make([]*Track, 0)}
+}
+
+// Returns the number of tracks in the queue
+func (q *Queue) Len() int {
+	return len(q.tracks)
+}
+
+// Returns a copy of the tracks in the queue
+func (q *Queue) Tracks() []*Track {
+	tracks := make([]*Track, len(q.tracks))
+	copy(tracks, q.tracks)
+	return tracks
+}
+
+// Returns the track at index i
+func (q *Queue) Get(i int) (*Track, error) {
+	if i < 0 || i >= len(q.tracks) {
+		return nil, ErrIndexRange
+	}
+
+	return q.tracks[i], nil
+}
+
+// Appends the tracks to the end of the queue
+func (q *Queue) Push(tracks ...*Track) {
+	q.tracks = append(q.tracks, tracks...)
+}
+
+// Removes and returns the first track of the queue
+func (q *Queue) Pop() (*Track, error) {
+	return q.Remove(0)
+}
+
+// Inserts the tracks before index i. If i equals
+// the length of the queue the tracks are appended.
+func (q *Queue) Insert(i int, tracks ...*Track) error {
+	if i < 0 || i > len(q.tracks) {
+		return ErrIndexRange
+	}
+
+	res := make([]*Track, 0, len(q.tracks)+len(tracks))
+	res = append(res, q.tracks[:i]...)
+	res = append(res, tracks...)
+	q.tracks = append(res, q.tracks[i:]...)
+	return nil
+}
+
+// Removes and returns the track at index i
+func (q *Queue) Remove(i int) (*Track, error) {
+	if i < 0 || i >= len(q.tracks) {
+		return nil, ErrIndexRange
+	}
+
+	track := q.tracks[i]
+	q.tracks = append(q.tracks[:i], q.tracks[i+1:]...)
+	return track, nil
+}
+
+// Removes and returns the tracks from index from to index to, inclusive
+func (q *Queue) RemoveRange(from, to int) ([]*Track, error) {
+	if from < 0 || to >= len(q.tracks) || from > to {
+		return nil, ErrIndexRange
+	}
+
+	removed := make([]*Track, to-from+1)
+	copy(removed, q.tracks[from:to+1])
+	q.tracks = append(q.tracks[:from], q.tracks[to+1:]...)
+	return removed, nil
+}
+
+// Moves the track at index from to index to,
+// shifting the tracks in between
+func (q *Queue) Move(from, to int) error {
+	if to < 0 || to >= len(q.tracks) {
+		return ErrIndexRange
+	}
+
+	track, err := q.Remove(from)
+	if err != nil {
+		return err
+	}
+
+	return q.Insert(to, track)
+}
+
+// Swaps the tracks at indexes i and j
+func (q *Queue) Swap(i, j int) error {
+	if i < 0 || i >= len(q.tracks) || j < 0 || j >= len(q.tracks) {
+		return ErrIndexRange
+	}
+
+	q.tracks[i], q.tracks[j] = q.tracks[j], q.tracks[i]
+	return nil
+}
+
+// Randomizes the order of the tracks using r
+func (q *Queue) Shuffle(r *rand.Rand) {
+	r.Shuffle(len(q.tracks), func(i, j int) {
+		q.tracks[i], q.tracks[j] = q.tracks[j], q.tracks[i]
+	})
+}
+
+// Removes all the tracks from the queue
+func (q *Queue) Clear() {
+	q.tracks = make([]*Track, 0)
+}
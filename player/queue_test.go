@@ -0,0 +1,229 @@
+package player
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Returns n tracks titled by their position
+func testTracks(n int) []*Track {
+	tracks := make([]*Track, n)
+	for i := range tracks {
+		tracks[i] = &Track{Title: fmt.Sprint(i)}
+	}
+	return tracks
+}
+
+// Returns the titles of the tracks
+func titles(tracks []*Track) []string {
+	res := make([]string, len(tracks))
+	for i, t := range tracks {
+		res[i] = t.Title
+	}
+	return res
+}
+
+func TestQueueShuffle(t *testing.T) {
+	tracks := testTracks(10)
+	q := NewQueue()
+	q.Push(tracks...)
+	q.Shuffle(rand.New(rand.NewSource(1)))
+
+	// The same seed gives the same order
+	want := make([]*Track, len(tracks))
+	copy(want, tracks)
+	r := rand.New(rand.NewSource(1))
+	r.Shuffle(len(want), func(i, j int) { want[i], want[j] = want[j], want[i] })
+
+	if got := titles(q.Tracks()); fmt.Sprint(got) != fmt.Sprint(titles(want)) {
+		t.Fatalf("got order %v, want %v", got, titles(want))
+	}
+
+	if fmt.Sprint(titles(q.Tracks())) == fmt.Sprint(titles(tracks)) {
+		t.Fatal("the order did not change")
+	}
+}
+
+func TestQueueShuffleSeeds(t *testing.T) {
+	orders := make([]string, 2)
+	for i := range orders {
+		q := NewQueue()
+		q.Push(testTracks(10)...)
+		q.Shuffle(rand.New(rand.NewSource(42)))
+		orders[i] = fmt.Sprint(titles(q.Tracks()))
+	}
+
+	if orders[0] != orders[1] {
+		t.Fatalf("the same seed gave %s and %s", orders[0], orders[1])
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     string
+		err      error
+	}{
+		{0, 2, "[1 2 0 3]", nil},
+		{3, 0, "[3 0 1 2]", nil},
+		{1, 1, "[0 1 2 3]", nil},
+		{4, 0, "[0 1 2 3]", ErrIndexRange},
+		{0, 4, "[0 1 2 3]", ErrIndexRange},
+	}
+
+	for _, test := range tests {
+		q := testQueue(4)
+		if err := q.Move(test.from, test.to); err != test.err {
+			t.Errorf("Move(%d, %d): got error %v, want %v", test.from, test.to, err, test.err)
+		}
+
+		if got := fmt.Sprint(titles(q.Tracks())); got != test.want {
+			t.Errorf("Move(%d, %d): got %s, want %s", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+// Returns a queue with n tracks titled by their position
+func testQueue(n int) *Queue {
+	q := NewQueue()
+	q.Push(testTracks(n)...)
+	return q
+}
+
+func TestQueueInsert(t *testing.T) {
+	tests := []struct {
+		at   int
+		want string
+		err  error
+	}{
+		{0, "[new 0 1 2]", nil},
+		{1, "[0 new 1 2]", nil},
+		{3, "[0 1 2 new]", nil},
+		{4, "[0 1 2]", ErrIndexRange},
+		{-1, "[0 1 2]", ErrIndexRange},
+	}
+
+	for _, test := range tests {
+		q := testQueue(3)
+		if err := q.Insert(test.at, &Track{Title: "new"}); err != test.err {
+			t.Errorf("Insert(%d): got error %v, want %v", test.at, err, test.err)
+		}
+
+		if got := fmt.Sprint(titles(q.Tracks())); got != test.want {
+			t.Errorf("Insert(%d): got %s, want %s", test.at, got, test.want)
+		}
+	}
+
+	// Inserting in an empty queue at its length appends
+	q := NewQueue()
+	if err := q.Insert(q.Len(), testTracks(2)...); err != nil || q.Len() != 2 {
+		t.Errorf("got error %v and %d tracks for an empty queue", err, q.Len())
+	}
+}
+
+func TestQueueRemoveRange(t *testing.T) {
+	tests := []struct {
+		from, to int
+		removed  string
+		want     string
+		err      error
+	}{
+		{0, 0, "[0]", "[1 2 3]", nil},
+		{3, 3, "[3]", "[0 1 2]", nil},
+		{1, 2, "[1 2]", "[0 3]", nil},
+		{0, 3, "[0 1 2 3]", "[]", nil},
+		{2, 1, "[]", "[0 1 2 3]", ErrIndexRange},
+		{-1, 1, "[]", "[0 1 2 3]", ErrIndexRange},
+		{2, 4, "[]", "[0 1 2 3]", ErrIndexRange},
+	}
+
+	for _, test := range tests {
+		q := testQueue(4)
+		removed, err := q.RemoveRange(test.from, test.to)
+		if err != test.err {
+			t.Errorf("RemoveRange(%d, %d): got error %v, want %v", test.from, test.to, err, test.err)
+		}
+
+		if got := fmt.Sprint(titles(removed)); got != test.removed {
+			t.Errorf("RemoveRange(%d, %d): removed %s, want %s", test.from, test.to, got, test.removed)
+		}
+
+		if got := fmt.Sprint(titles(q.Tracks())); got != test.want {
+			t.Errorf("RemoveRange(%d, %d): got %s, want %s", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestQueueRemove(t *testing.T) {
+	tests := []struct {
+		at      int
+		removed string
+		want    string
+		err     error
+	}{
+		{0, "0", "[1 2]", nil},
+		{2, "2", "[0 1]", nil},
+		{3, "", "[0 1 2]", ErrIndexRange},
+		{-1, "", "[0 1 2]", ErrIndexRange},
+	}
+
+	for _, test := range tests {
+		q := testQueue(3)
+		track, err := q.Remove(test.at)
+		if err != test.err {
+			t.Errorf("Remove(%d): got error %v, want %v", test.at, err, test.err)
+		}
+
+		if track != nil && track.Title != test.removed {
+			t.Errorf("Remove(%d): removed %s, want %s", test.at, track.Title, test.removed)
+		}
+
+		if got := fmt.Sprint(titles(q.Tracks())); got != test.want {
+			t.Errorf("Remove(%d): got %s, want %s", test.at, got, test.want)
+		}
+	}
+}
+
+func TestQueueSwap(t *testing.T) {
+	tests := []struct {
+		i, j int
+		want string
+		err  error
+	}{
+		{0, 2, "[2 1 0]", nil},
+		{2, 0, "[2 1 0]", nil},
+		{1, 1, "[0 1 2]", nil},
+		{0, 3, "[0 1 2]", ErrIndexRange},
+		{-1, 0, "[0 1 2]", ErrIndexRange},
+	}
+
+	for _, test := range tests {
+		q := testQueue(3)
+		if err := q.Swap(test.i, test.j); err != test.err {
+			t.Errorf("Swap(%d, %d): got error %v, want %v", test.i, test.j, err, test.err)
+		}
+
+		if got := fmt.Sprint(titles(q.Tracks())); got != test.want {
+			t.Errorf("Swap(%d, %d): got %s, want %s", test.i, test.j, got, test.want)
+		}
+	}
+}
+
+func TestQueuePop(t *testing.T) {
+	q := testQueue(2)
+	for _, want := range []string{"0", "1"} {
+		track, err := q.Pop()
+		if err != nil || track.Title != want {
+			t.Fatalf("got %v and %v, want track %s", track, err, want)
+		}
+	}
+
+	if _, err := q.Pop(); err != ErrIndexRange {
+		t.Fatalf("got error %v from an empty queue, want %v", err, ErrIndexRange)
+	}
+
+	if _, err := q.Get(0); err != ErrIndexRange {
+		t.Fatalf("got error %v from an empty queue, want %v", err, ErrIndexRange)
+	}
+}
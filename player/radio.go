@@ -0,0 +1,432 @@
+package player
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"layeh.com/gumble/gumbleffmpeg"
+)
+
+// How long a radio stream can send no data before it is closed
+const radioIdleTimeout = 30 * time.Second
+
+// How long the response of a station can take when it is checked
+const radioRequestTimeout = 30 * time.Second
+
+// The maximum size of a radio playlist file that is read
+const maxRadioPlaylistSize = 64 * 1024
+
+// The playlist formats of radio stations by their content type
+var radioPlaylistTypes = map[string]string{
+	"audio/x-scpls":   ".pls",
+	"audio/x-mpegurl": ".m3u",
+	"audio/mpegurl":   ".m3u",
+}
+
+// An entry of a radio playlist file
+type playlistEntry struct {
+	URL   string
+	Title string
+}
+
+// The source for internet radio stations. It handles Icecast and
+// SHOUTcast streams and the .pls and .m3u files that point to them.
+type RadioSource struct {
+	client *http.Client
+}
+
+// Creates and returns a radio source
+func NewRadioSource() *RadioSource {
+	return &RadioSource{client: http.DefaultClient}
+}
+
+// Returns whether the URL is a radio playlist file or a stream that sends
+// ICY headers. The extension of the URL is checked first and if it is
+// not a playlist the headers of the response.
+func (s *RadioSource) CanHandle(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	if playlistType(u, "") != "" {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), radioRequestTimeout)
+	defer cancel()
+	resp, err := s.get(ctx, u.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	return isICYResponse(resp) || playlistType(u, resp.Header.Get("Content-Type")) != ""
+}
+
+// Returns the track of the radio station. The track has no duration
+// since the stream is infinite.
+func (s *RadioSource) Tracks(u *url.URL) ([]*Track, error) {
+	station, err := s.station(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if station.Title == "" {
+		station.Title = u.Host
+	}
+
+	return []*Track{{
+		Title:      station.Title,
+		Artist:     u.Host,
+		PublicURL:  u.String(),
+		StreamURL:  station.URL,
+		Live:       true,
+		resolveURL: s.streamURL(u),
+		openSource: s.openSource,
+	}}, nil
+}
+
+// Returns the stream of the station at the URL. If the URL
+// is a playlist file the stream is its first entry.
+func (s *RadioSource) station(u *url.URL) (*playlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), radioRequestTimeout)
+	defer cancel()
+	resp, err := s.get(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrIncorrectURL
+	}
+
+	kind := playlistType(u, resp.Header.Get("Content-Type"))
+	if kind == "" {
+		return &playlistEntry{URL: u.String(), Title: resp.Header.Get("icy-name")}, nil
+	}
+
+	entries, err := parseRadioPlaylist(kind, io.LimitReader(resp.Body, maxRadioPlaylistSize), resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrEmptyPlaylist
+	}
+
+	return &entries[0], nil
+}
+
+// Returns a function that reads the stream URL of the station again,
+// since the playlist file of a station can point to a different server
+func (s *RadioSource) streamURL(u *url.URL) func() (string, error) {
+	return func() (string, error) {
+		station, err := s.station(u)
+		if err != nil {
+			return "", err
+		}
+
+		return station.URL, nil
+	}
+}
+
+// Returns a source that streams the station from the URL. The ICY
+// metadata are removed from the audio and the stream titles are passed
+// to onTitle. The stream is read in the background through a pipe,
+// so that stopping the source never waits for the connection.
+func (s *RadioSource) openSource(streamURL string, onTitle func(string)) (gumbleffmpeg.Source, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	go s.copyStream(streamURL, pw, onTitle)
+	return gumbleffmpeg.SourceReader(pr), nil
+}
+
+// Copies the audio of the stream to w until the stream ends,
+// sends no data for too long or the other end of w is closed
+func (s *RadioSource) copyStream(streamURL string, w io.WriteCloser, onTitle func(string)) {
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idle := time.AfterFunc(radioIdleTimeout, cancel)
+	defer idle.Stop()
+
+	resp, err := s.get(ctx, streamURL)
+	if err != nil {
+		log.Printf("Could not connect to %s: %v\n", streamURL, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Could not connect to %s: %s\n", streamURL, resp.Status)
+		return
+	}
+
+	// Without a metadata interval the stream is only audio
+	metaint, _ := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	r := &icyReader{r: resp.Body, metaint: metaint, remaining: metaint, onTitle: onTitle}
+
+	buf := make([]byte, 16*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			idle.Reset(radioIdleTimeout)
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Sends a GET request that asks the server for the ICY metadata
+func (s *RadioSource) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Icy-MetaData", "1")
+	return s.client.Do(req)
+}
+
+// Returns whether the response is from an Icecast or SHOUTcast server
+func isICYResponse(resp *http.Response) bool {
+	for _, header := range []string{"icy-metaint", "icy-name", "icy-br", "ice-audio-info"} {
+		if resp.Header.Get(header) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the extension of the playlist format of the URL, from its
+// extension or its content type, or an empty string if it is not a playlist
+func playlistType(u *url.URL, contentType string) string {
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == ".pls" || ext == ".m3u" {
+		return ext
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return radioPlaylistTypes[mediaType]
+}
+
+// Reads the entries of a playlist file of the format. Relative
+// URLs are resolved against the URL of the playlist.
+func parseRadioPlaylist(kind string, r io.Reader, base *url.URL) ([]playlistEntry, error) {
+	var entries []playlistEntry
+	var err error
+	if kind == ".pls" {
+		entries, err = parsePLS(r)
+	} else {
+		entries, err = parseM3U(r)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]playlistEntry, 0, len(entries))
+	for _, e := range entries {
+		u, err := base.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		e.URL = u.String()
+		resolved = append(resolved, e)
+	}
+
+	return resolved, nil
+}
+
+// Reads the entries of a PLS file, which has a FileN
+// and an optional TitleN key for each entry N
+func parsePLS(r io.Reader) ([]playlistEntry, error) {
+	byNumber := make(map[int]*playlistEntry)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		var field string
+		switch {
+		case strings.HasPrefix(key, "file"):
+			field = "file"
+		case strings.HasPrefix(key, "title"):
+			field = "title"
+		default:
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+		if err != nil {
+			continue
+		}
+
+		if byNumber[n] == nil {
+			byNumber[n] = new(playlistEntry)
+		}
+
+		if field == "file" {
+			byNumber[n].URL = value
+		} else {
+			byNumber[n].Title = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(byNumber))
+	for n, e := range byNumber {
+		if e.URL != "" {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	entries := make([]playlistEntry, len(numbers))
+	for i, n := range numbers {
+		entries[i] = *byNumber[n]
+	}
+	return entries, nil
+}
+
+// Reads the entries of an M3U file. The title of an entry
+// is read from the #EXTINF line before it if there is one.
+func parseM3U(r io.Reader) ([]playlistEntry, error) {
+	var entries []playlistEntry
+	var title string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			if parts := strings.SplitN(line, ",", 2); len(parts) == 2 {
+				title = strings.TrimSpace(parts[1])
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			entries = append(entries, playlistEntry{URL: line, Title: title})
+			title = ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Removes the ICY metadata blocks from a stream. A block is sent
+// after every metaint bytes of audio and its first byte is its
+// length divided by 16. The stream titles of the blocks are passed to onTitle.
+type icyReader struct {
+	r         io.Reader
+	metaint   int
+	remaining int
+	onTitle   func(string)
+}
+
+// Reads the audio of the stream to p
+func (ir *icyReader) Read(p []byte) (int, error) {
+	if ir.metaint <= 0 {
+		return ir.r.Read(p)
+	}
+
+	if ir.remaining == 0 {
+		if err := ir.readMetadata(); err != nil {
+			return 0, err
+		}
+		ir.remaining = ir.metaint
+	}
+
+	if len(p) > ir.remaining {
+		p = p[:ir.remaining]
+	}
+
+	n, err := ir.r.Read(p)
+	ir.remaining -= n
+	return n, err
+}
+
+// Reads a metadata block and passes its stream title to onTitle
+func (ir *icyReader) readMetadata() error {
+	var length [1]byte
+	if _, err := io.ReadFull(ir.r, length[:]); err != nil {
+		return err
+	}
+
+	if length[0] == 0 {
+		return nil
+	}
+
+	metadata := make([]byte, int(length[0])*16)
+	if _, err := io.ReadFull(ir.r, metadata); err != nil {
+		return err
+	}
+
+	if title, ok := parseStreamTitle(string(metadata)); ok {
+		ir.onTitle(title)
+	}
+	return nil
+}
+
+// Returns the stream title of an ICY metadata block
+// of the form "StreamTitle='Artist - Song';StreamUrl=”;"
+func parseStreamTitle(metadata string) (string, bool) {
+	const prefix = "StreamTitle='"
+	start := strings.Index(metadata, prefix)
+	if start < 0 {
+		return "", false
+	}
+
+	rest := metadata[start+len(prefix):]
+	end := strings.Index(rest, "';")
+	if end < 0 {
+		end = strings.LastIndex(rest, "'")
+	}
+	if end < 0 {
+		return "", false
+	}
+
+	return strings.TrimSpace(rest[:end]), true
+}
@@ -0,0 +1,43 @@
+package player
+
+import "errors"
+
+var ErrRepeatMode = errors.New("unknown repeat mode")
+
+// The repeat mode of the player
+type RepeatMode int
+
+const (
+	// Finished tracks are discarded
+	RepeatOff RepeatMode = iota
+	// The current track is played again until it is skipped
+	RepeatOne
+	// Finished tracks are added back to the end of the queue
+	RepeatAll
+)
+
+// Returns the name of the repeat mode
+func (m RepeatMode) String() string {
+	switch m {
+	case RepeatOne:
+		return "one"
+	case RepeatAll:
+		return "all"
+	default:
+		return "off"
+	}
+}
+
+// Receives the name of a repeat mode and returns the mode
+func ParseRepeatMode(name string) (RepeatMode, error) {
+	switch name {
+	case "off":
+		return RepeatOff, nil
+	case "one":
+		return RepeatOne, nil
+	case "all":
+		return RepeatAll, nil
+	default:
+		return RepeatOff, ErrRepeatMode
+	}
+}
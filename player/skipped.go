@@ -0,0 +1,75 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// A video of a playlist that could not be added and the reason
+type SkippedVideo struct {
+	Title string
+	Err   error
+}
+
+// Returns the reason the video was skipped for displaying
+func (v SkippedVideo) Reason() string {
+	var status *youtube.ErrPlayabiltyStatus
+	switch {
+	case errors.As(v.Err, &status) && status.Reason != "":
+		return status.Reason
+	case errors.Is(v.Err, ErrNoFormat):
+		return "no audio format found"
+	case errors.Is(v.Err, ErrThumbDownload):
+		return "could not get the thumbnail"
+	default:
+		return v.Err.Error()
+	}
+}
+
+// Returned together with the tracks of a playlist when some of its
+// videos could not be added. The tracks that could be added are
+// still returned, so callers should keep them.
+type SkippedError struct {
+	Skipped []SkippedVideo
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("%d videos of the playlist were skipped", len(e.Skipped))
+}
+
+// Adds the skipped videos to the error. If the error is nil a SkippedError
+// with them is returned and if it is a SkippedError they are appended to it.
+// Other errors are returned unchanged.
+func withSkipped(err error, skipped []SkippedVideo) error {
+	if len(skipped) == 0 {
+		return err
+	}
+
+	if err == nil {
+		return &SkippedError{Skipped: skipped}
+	}
+
+	var s *SkippedError
+	if errors.As(err, &s) {
+		s.Skipped = append(s.Skipped, skipped...)
+	}
+	return err
+}
+
+// Returns the skipped videos of the error if it is a SkippedError
+func skippedVideos(err error) []SkippedVideo {
+	var s *SkippedError
+	if errors.As(err, &s) {
+		return s.Skipped
+	}
+	return nil
+}
+
+// Returns whether the error only reports that some videos were skipped
+// and there are tracks that can still be added
+func isPartial(tracks []*Track, err error) bool {
+	var skipped *SkippedError
+	return len(tracks) > 0 && errors.As(err, &skipped)
+}
@@ -0,0 +1,135 @@
+package player
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// The client that follows the redirections of unknown URLs
+var redirectClient = &http.Client{Timeout: httpRequestTimeout}
+
+// A provider of tracks, like a website or a file server
+type Source interface {
+	// Returns whether the source can get tracks from the URL
+	CanHandle(u *url.URL) bool
+	// Returns the tracks of the URL
+	Tracks(u *url.URL) ([]*Track, error)
+}
+
+// A source that can import the tracks of a URL with options, like
+// the videos of a playlist up to a limit. The registry passes the
+// options to the sources that implement it.
+type Importer interface {
+	Source
+	// Returns the tracks of the URL. Tracks that are skipped are
+	// reported with a SkippedError together with the rest of the tracks.
+	Import(u *url.URL, opts ImportOptions) ([]*Track, error)
+}
+
+// A list of sources that is searched in order for
+// the first one that can handle a URL. Sources must be
+// registered before the registry is used.
+type Registry struct {
+	sources []Source
+}
+
+// Creates and returns a registry with the sources
+func NewRegistry(sources ...Source) *Registry {
+	return &Registry{sources: sources}
+}
+
+// Adds the source to the end of the registry
+func (r *Registry) Register(s Source) {
+	r.sources = append(r.sources, s)
+}
+
+// Returns the first source that can handle the URL or nil
+func (r *Registry) Find(u *url.URL) Source {
+	for _, s := range r.sources {
+		if s.CanHandle(u) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Returns the tracks of the URL from the first source that can handle it.
+// If no source can handle it the redirections of the URL are followed,
+// so that short links are resolved, and the sources are searched again.
+func (r *Registry) Tracks(u *url.URL) ([]*Track, error) {
+	s, u, err := r.findSource(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Tracks(u)
+}
+
+// Returns the tracks of the URL like Tracks. If the source is
+// an importer the options are passed to it and otherwise they are
+// ignored. Sources that are not importers return all their tracks.
+func (r *Registry) Import(u *url.URL, opts ImportOptions) ([]*Track, error) {
+	s, u, err := r.findSource(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if importer, ok := s.(Importer); ok {
+		return importer.Import(u, opts)
+	}
+	return s.Tracks(u)
+}
+
+// Returns the first source that can handle the URL and the URL it
+// handles, which is the final redirection if the URL is not handled
+func (r *Registry) findSource(u *url.URL) (Source, *url.URL, error) {
+	if s := r.Find(u); s != nil {
+		return s, u, nil
+	}
+
+	redirectURL, err := getRedirectURL(u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s := r.Find(redirectURL); s != nil {
+		return s, redirectURL, nil
+	}
+
+	return nil, nil, ErrIncorrectURL
+}
+
+// Receives a public URL of a track and returns a new track from it
+func (r *Registry) Track(rawURL string) (*Track, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks, err := r.Tracks(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tracks) != 1 {
+		return nil, ErrIncorrectURL
+	}
+
+	return tracks[0], nil
+}
+
+// Receives a url and returns the final redirection url
+func getRedirectURL(url *url.URL) (*url.URL, error) {
+	resp, err := redirectClient.Head(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrIncorrectURL
+	}
+
+	return resp.Request.URL, nil
+}
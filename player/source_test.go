@@ -0,0 +1,191 @@
+package player
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// A source for tests that handles the URLs of its host
+// whose path starts with its path
+type fakeSource struct {
+	host string
+	path string
+	// Returns the tracks of a URL. If it is nil
+	// a URL has one track titled by its path.
+	tracks func(u *url.URL) ([]*Track, error)
+}
+
+func (s *fakeSource) CanHandle(u *url.URL) bool {
+	return u.Host == s.host && strings.HasPrefix(u.Path, s.path)
+}
+
+func (s *fakeSource) Tracks(u *url.URL) ([]*Track, error) {
+	if s.tracks != nil {
+		return s.tracks(u)
+	}
+
+	return []*Track{{Title: u.Path, PublicURL: u.String()}}, nil
+}
+
+// Returns a source that handles the host and whose
+// tracks have the artist so that it can be told apart
+func namedSource(host, path, name string) *fakeSource {
+	return &fakeSource{
+		host: host,
+		path: path,
+		tracks: func(u *url.URL) ([]*Track, error) {
+			return []*Track{{Title: u.Path, Artist: name, PublicURL: u.String()}}, nil
+		},
+	}
+}
+
+func TestRegistryOrder(t *testing.T) {
+	r := NewRegistry(namedSource("a.test", "/only", "first"), namedSource("a.test", "", "second"))
+	r.Register(namedSource("a.test", "", "third"))
+	r.Register(namedSource("b.test", "", "fourth"))
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://a.test/only/1", "first"},
+		{"http://a.test/other", "second"},
+		{"http://b.test/", "fourth"},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		tracks, err := r.Tracks(u)
+		if err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+
+		if len(tracks) != 1 || tracks[0].Artist != test.want {
+			t.Errorf("%s: got the tracks of %s, want %s", test.url, tracks[0].Artist, test.want)
+		}
+	}
+}
+
+func TestRegistryRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/short", http.RedirectHandler("/long/song", http.StatusFound))
+	mux.HandleFunc("/long/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	r := NewRegistry(namedSource(host, "/long/", "long"))
+
+	u, _ := url.Parse(server.URL + "/short")
+	tracks, err := r.Tracks(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 1 || tracks[0].PublicURL != server.URL+"/long/song" {
+		t.Fatalf("got tracks %v, want the track of the redirection", tracks)
+	}
+
+	// Unknown URLs that do not redirect to a known one are rejected
+	for _, path := range []string{"/other", "/missing"} {
+		u, _ := url.Parse(server.URL + path)
+		if _, err := r.Tracks(u); !errors.Is(err, ErrIncorrectURL) {
+			t.Errorf("%s: got error %v, want %v", path, err, ErrIncorrectURL)
+		}
+	}
+}
+
+func TestRegistryTrack(t *testing.T) {
+	r := NewRegistry(
+		&fakeSource{
+			host: "many.test",
+			tracks: func(u *url.URL) ([]*Track, error) {
+				return testTracks(2), nil
+			},
+		},
+		&fakeSource{
+			host: "fail.test",
+			tracks: func(u *url.URL) ([]*Track, error) {
+				return nil, ErrEmptyPlaylist
+			},
+		},
+		&fakeSource{host: "one.test"},
+	)
+
+	track, err := r.Track("http://one.test/song")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track.Title != "/song" {
+		t.Fatalf("got track %s, want /song", track.Title)
+	}
+
+	if _, err := r.Track("http://many.test/playlist"); !errors.Is(err, ErrIncorrectURL) {
+		t.Errorf("got error %v for many tracks, want %v", err, ErrIncorrectURL)
+	}
+
+	if _, err := r.Track("http://fail.test/"); !errors.Is(err, ErrEmptyPlaylist) {
+		t.Errorf("got error %v, want the error of the source", err)
+	}
+
+	if _, err := r.Track("://bad"); err == nil {
+		t.Error("got no error for an invalid URL")
+	}
+}
+
+// A source for tests that imports a track for each video
+// up to the limit and records the options it received
+type fakeImporter struct {
+	fakeSource
+	videos int
+	opts   *ImportOptions
+}
+
+func (s *fakeImporter) Import(u *url.URL, opts ImportOptions) ([]*Track, error) {
+	s.opts = &opts
+	n := s.videos
+	if opts.Limit > 0 && opts.Limit < n {
+		n = opts.Limit
+	}
+
+	if opts.Progress != nil {
+		opts.Progress(n, s.videos)
+	}
+	return testTracks(n), nil
+}
+
+// The youtube source gets the options of the player through the registry
+var _ Importer = (*YoutubeSource)(nil)
+
+func TestRegistryImport(t *testing.T) {
+	importer := &fakeImporter{fakeSource: fakeSource{host: "import.test"}, videos: 5}
+	plain := &fakeSource{host: "plain.test"}
+	r := NewRegistry(importer, plain)
+
+	u, _ := url.Parse("http://import.test/playlist")
+	tracks, err := r.Import(u, ImportOptions{Mode: PlaylistAll, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 3 || importer.opts == nil || importer.opts.Mode != PlaylistAll || importer.opts.Limit != 3 {
+		t.Fatalf("got %d tracks and options %+v", len(tracks), importer.opts)
+	}
+
+	// Sources that are not importers ignore the options
+	u, _ = url.Parse("http://plain.test/song")
+	tracks, err = r.Import(u, ImportOptions{Mode: PlaylistAll, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 1 || tracks[0].Title != "/song" {
+		t.Fatalf("got tracks %v, want the track of the source", tracks)
+	}
+}
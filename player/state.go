@@ -0,0 +1,219 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The state of the player that is saved to the state file.
+// Tracks are saved by their public URL and resolved again when loaded.
+type State struct {
+	Queue           []string `json:"queue"`
+	CurrentTrack    string   `json:"current_track"`
+	PositionSeconds float64  `json:"position_seconds"`
+	Playing         bool     `json:"playing"`
+	Volume          int      `json:"volume"`
+	Repeat          string   `json:"repeat"`
+	Shuffle         bool     `json:"shuffle"`
+	// The radio stations saved with the radio command.
+	// They are added to the stations of the config.
+	Stations map[string]string `json:"stations,omitempty"`
+	// The stations of the config removed with the radio command
+	RemovedStations []string `json:"removed_stations,omitempty"`
+}
+
+// Saves the state of the player to the state file if there is one.
+// Errors are only logged since playback does not depend on them.
+// The mutex must be held.
+func (p *Player) saveState() {
+	if p.stateFile == "" || p.closed {
+		return
+	}
+
+	state := State{
+		Queue:   make([]string, 0, p.queue.Len()),
+		Playing: p.playing,
+		Volume:  int(p.volume*100 + 0.5),
+		Repeat:  p.repeat.String(),
+		Shuffle: p.shuffle,
+	}
+	state.Stations, state.RemovedStations = p.changedStations()
+
+	if p.currentTrack != nil {
+		state.CurrentTrack = p.currentTrack.PublicURL
+		state.PositionSeconds = p.currentTrack.position().Seconds()
+	}
+
+	for _, track := range p.queue.Tracks() {
+		state.Queue = append(state.Queue, track.PublicURL)
+	}
+
+	if err := writeState(p.stateFile, &state); err != nil {
+		log.Printf("Could not save the state: %v\n", err)
+	}
+}
+
+// Returns the stations that were saved or replaced and the names of
+// the stations of the config that were removed. The mutex must be held.
+func (p *Player) changedStations() (map[string]string, []string) {
+	var saved map[string]string
+	for name, rawURL := range p.stations {
+		if p.configStations[name] == rawURL {
+			continue
+		}
+		if saved == nil {
+			saved = make(map[string]string)
+		}
+		saved[name] = rawURL
+	}
+
+	var removed []string
+	for name := range p.configStations {
+		if _, ok := p.stations[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	return saved, removed
+}
+
+// Writes the state to a temporary file and renames it
+// to path so that the file is never partially written
+func writeState(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// Loads the state from the state file and resolves its tracks again.
+// Tracks that cannot be resolved are skipped. The current track is
+// restored paused at its saved position. Returns whether the player
+// was playing when the state was saved.
+func (p *Player) LoadState() (bool, error) {
+	if p.stateFile == "" {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(p.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	state := new(State)
+	if err := json.Unmarshal(data, state); err != nil {
+		return false, err
+	}
+
+	// The current track is resolved together with the queue
+	urls := state.Queue
+	if state.CurrentTrack != "" {
+		urls = append([]string{state.CurrentTrack}, urls...)
+	}
+	restored := p.restoreTracks(urls)
+
+	var current *Track
+	if state.CurrentTrack != "" {
+		current, restored = restored[0], restored[1:]
+		if current != nil && !current.Live {
+			current.Offset = time.Duration(state.PositionSeconds * float64(time.Second))
+		}
+	}
+
+	tracks := make([]*Track, 0, len(restored))
+	for _, track := range restored {
+		if track != nil {
+			tracks = append(tracks, track)
+		}
+	}
+
+	repeat, err := ParseRepeatMode(state.Repeat)
+	if err != nil {
+		repeat = RepeatOff
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if current != nil {
+		p.currentTrack = current
+		p.paused = true
+	}
+	p.queue.Push(tracks...)
+	p.repeat = repeat
+	p.shuffle = state.Shuffle
+	for name, rawURL := range state.Stations {
+		p.stations[strings.ToLower(name)] = rawURL
+	}
+	for _, name := range state.RemovedStations {
+		delete(p.stations, strings.ToLower(name))
+	}
+	if state.Volume >= 0 && state.Volume <= 100 {
+		p.volume = float32(state.Volume) / 100
+	}
+
+	return state.Playing, nil
+}
+
+// Resolves the public URLs to tracks with the import workers of the
+// player at the same time. The tracks keep the order of the URLs and
+// the tracks of the URLs that cannot be resolved are nil.
+func (p *Player) restoreTracks(urls []string) []*Track {
+	// Each worker writes only the indexes it receives
+	tracks := make([]*Track, len(urls))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < p.importWorkers && w < len(urls); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				track, err := p.sources.Track(urls[i])
+				if err != nil {
+					log.Printf("Could not restore %s: %v\n", urls[i], err)
+					continue
+				}
+				tracks[i] = track
+			}
+		}()
+	}
+
+	for i := range urls {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return tracks
+}
+
+// Saves the state and stops the playlist. The state
+// is not saved again so that it can be restored on startup.
+func (p *Player) Close() error {
+	p.mutex.Lock()
+	p.saveState()
+	p.closed = true
+	p.mutex.Unlock()
+
+	err := p.Stop()
+	if errors.Is(err, ErrStopped) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Writes the state to a file in a temporary directory and returns its path
+func writeStateFile(t *testing.T, state *State) string {
+	t.Helper()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadState(t *testing.T) {
+	state := &State{
+		CurrentTrack:    "http://fake.test/current",
+		PositionSeconds: 42,
+		Playing:         true,
+		Volume:          30,
+		Repeat:          "all",
+		Shuffle:         true,
+	}
+	for i := 0; i < 20; i++ {
+		state.Queue = append(state.Queue, fmt.Sprintf("http://fake.test/%d", i))
+	}
+	state.Queue = append(state.Queue, "http://fake.test/bad")
+
+	p := New(Config{StateFile: writeStateFile(t, state), ImportWorkers: 4})
+	p.sources = NewRegistry(&fakeSource{
+		host: "fake.test",
+		tracks: func(u *url.URL) ([]*Track, error) {
+			if u.Path == "/bad" {
+				return nil, errors.New("cannot resolve")
+			}
+
+			// The tracks finish resolving out of order
+			var n int
+			fmt.Sscanf(u.Path, "/%d", &n)
+			time.Sleep(time.Duration(n%3) * time.Millisecond)
+			return []*Track{{Title: u.Path, PublicURL: u.String()}}, nil
+		},
+	})
+
+	playing, err := p.LoadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !playing || !p.paused || p.volume != 0.3 || p.repeat != RepeatAll || !p.shuffle {
+		t.Fatalf("the modes were not restored: %+v", p)
+	}
+
+	if p.currentTrack == nil || p.currentTrack.Title != "/current" || p.currentTrack.Offset != 42*time.Second {
+		t.Fatalf("got current track %+v", p.currentTrack)
+	}
+
+	got := titles(p.queue.Tracks())
+	if len(got) != 20 {
+		t.Fatalf("got %d tracks, want 20", len(got))
+	}
+
+	for i, title := range got {
+		if want := fmt.Sprintf("/%d", i); title != want {
+			t.Fatalf("got track %s at %d, want %s", title, i, want)
+		}
+	}
+}
+
+func TestStateStations(t *testing.T) {
+	state := &State{
+		Stations:        map[string]string{"saved": "http://radio.test/saved", "replaced": "http://radio.test/new"},
+		RemovedStations: []string{"removed"},
+	}
+	path := writeStateFile(t, state)
+	p := New(Config{
+		StateFile: path,
+		Stations: map[string]string{
+			"Config":   "http://radio.test/config",
+			"Replaced": "http://radio.test/old",
+			"Removed":  "http://radio.test/removed",
+		},
+	})
+
+	if _, err := p.LoadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved changes are merged over the stations of the config
+	want := map[string]string{
+		"config":   "http://radio.test/config",
+		"replaced": "http://radio.test/new",
+		"saved":    "http://radio.test/saved",
+	}
+	if fmt.Sprint(p.stations) != fmt.Sprint(want) {
+		t.Fatalf("got stations %v, want %v", p.stations, want)
+	}
+
+	// Only the changes are saved again
+	p.mutex.Lock()
+	p.saveState()
+	p.mutex.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := new(State)
+	if err := json.Unmarshal(data, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(saved.Stations, saved.RemovedStations) != fmt.Sprint(state.Stations, state.RemovedStations) {
+		t.Fatalf("got saved stations %v and removed %v", saved.Stations, saved.RemovedStations)
+	}
+}
@@ -0,0 +1,128 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNoStation  = errors.New("no station with that name")
+	ErrNoStations = errors.New("there are no saved stations")
+	ErrNotStation = errors.New("the URL is not a radio station")
+)
+
+// Saves the URL of a radio station with the name, replacing the station
+// with the same name if there is one. Names are not case sensitive.
+// Only URLs of the radio source can be saved. Returns the track of the station.
+func (p *Player) AddStation(name string, u *url.URL) (*Track, error) {
+	// The URL is checked before it is saved
+	if !p.radio.CanHandle(u) {
+		return nil, ErrNotStation
+	}
+
+	tracks, err := p.radio.Tracks(u)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if p.stations == nil {
+		p.stations = make(map[string]string)
+	}
+	p.stations[strings.ToLower(name)] = u.String()
+	return tracks[0], nil
+}
+
+// Removes the saved station with the name
+func (p *Player) RemoveStation(name string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	name = strings.ToLower(name)
+	if _, ok := p.stations[name]; !ok {
+		return ErrNoStation
+	}
+
+	delete(p.stations, name)
+	return nil
+}
+
+// Returns the saved stations sorted by name
+func (p *Player) GetStations() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.stations) == 0 {
+		return "", ErrNoStations
+	}
+
+	names := make([]string, 0, len(p.stations))
+	for name := range p.stations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := "<br><b>"
+	for _, name := range names {
+		list += fmt.Sprintf("%s: <a href=\"%s\">%s</a><br>", name, p.stations[name], p.stations[name])
+	}
+	list += "</b>"
+	return list, nil
+}
+
+// Plays the saved station with the name. The current track
+// is skipped and the playlist continues after the station ends.
+// Returns the track of the station.
+func (p *Player) PlayStation(name string) (*Track, error) {
+	p.mutex.Lock()
+	rawURL, ok := p.stations[strings.ToLower(name)]
+	p.mutex.Unlock()
+	if !ok {
+		return nil, ErrNoStation
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stations of the config are played even if they are not
+	// recognized, like streams that do not send ICY headers
+	tracks, err := p.radio.Tracks(u)
+	if err != nil {
+		return nil, err
+	}
+	track := tracks[0]
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	defer p.saveState()
+
+	if p.queueSpace() == 0 {
+		return nil, ErrQueueFull
+	}
+
+	// The station is played next even if shuffle is on
+	p.queue.Insert(0, track)
+	p.pickedTracks++
+	switch {
+	case p.playing && p.currentTrack != nil:
+		p.seeking = false
+		p.skipped = true
+		p.currentTrack.stopStream()
+	case p.paused:
+		p.currentTrack = nil
+		p.startLocked()
+	case !p.playing:
+		p.startLocked()
+	}
+
+	return track, nil
+}
@@ -0,0 +1,52 @@
+package player
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAddStation(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("icy-name", "Test FM")
+		w.Header().Set("Content-Type", "audio/mpeg")
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	})
+	mux.HandleFunc("/song", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := New(Config{})
+	u, _ := url.Parse(server.URL + "/stream")
+	track, err := p.AddStation("Test", u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !track.Live || track.Title != "Test FM" {
+		t.Fatalf("got track %+v, want the live station", track)
+	}
+
+	if p.stations["test"] != u.String() {
+		t.Fatalf("got stations %v", p.stations)
+	}
+
+	// Other URLs that the player can play are not stations
+	for _, path := range []string{"/page", "/song"} {
+		u, _ := url.Parse(server.URL + path)
+		if _, err := p.AddStation(path, u); !errors.Is(err, ErrNotStation) {
+			t.Errorf("%s: got error %v, want %v", path, err, ErrNotStation)
+		}
+	}
+
+	if len(p.stations) != 1 {
+		t.Fatalf("got stations %v, want only the radio station", p.stations)
+	}
+}
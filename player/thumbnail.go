@@ -8,6 +8,10 @@ import (
 	"net/http"
 )
 
+// The maximum size of the encoded artwork of a podcast or an album.
+// Larger images are not shown since Mumble servers limit the length of messages.
+const maxArtworkSize = 100 * 1024
+
 var ErrThumbDownload = errors.New("could not get thumbnail")
 
 type Thumbnail struct {
@@ -41,3 +45,11 @@ func NewThumbnail(url string) (*Thumbnail, error) {
 		Data:     buf.Bytes(),
 	}, nil
 }
+
+// Initializes a new thumbnail from the data of an image
+func NewThumbnailFromImage(data []byte) *Thumbnail {
+	return &Thumbnail{
+		MimeType: http.DetectContentType(data),
+		Data:     []byte(base64.StdEncoding.EncodeToString(data)),
+	}
+}
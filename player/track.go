@@ -2,14 +2,15 @@ package player
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/kkdai/youtube/v2"
 	"layeh.com/gumble/gumble"
 	"layeh.com/gumble/gumbleffmpeg"
 )
 
 type Track struct {
+	// The stream of the track while it is playing
 	Stream    *gumbleffmpeg.Stream
 	Duration  time.Duration
 	StreamURL string
@@ -17,6 +18,26 @@ type Track struct {
 	Title     string
 	Artist    string
 	Thumbnail *Thumbnail
+	// The position the next stream of the track starts from
+	Offset time.Duration
+	// Whether the track is an infinite stream, like a radio station
+	Live bool
+	// Returns a new stream URL for the track. It is called
+	// just before the track is played since stream URLs expire.
+	resolveURL func() (string, error)
+	// Whether the stream URL has been resolved again after it failed
+	reresolved bool
+	// Guards the stream URL since the next track
+	// can be resolved while the current one plays
+	urlMutex sync.Mutex
+	// Returns the source of a stream of the track. It is nil for tracks
+	// that are streamed from their URL. Sources that read metadata while
+	// the track plays pass its stream title to onTitle.
+	openSource func(streamURL string, onTitle func(string)) (gumbleffmpeg.Source, error)
+	// The title of the song that a live stream is playing
+	streamTitle string
+	// Guards the stream title since it is set while the track plays
+	titleMutex sync.Mutex
 }
 
 // Returns the string for displaying the track
@@ -24,102 +45,92 @@ func (t *Track) String() string {
 	title := fmt.Sprintf("<h3 style=\"margin: 0px; padding: 0px;\"><a style=\"margin: 0px; padding: 0px;\" href=\"%s\">%s</a></h3>", t.PublicURL, t.Title)
 	artist := fmt.Sprintf("<h4 style=\"margin: 0px; padding: 0px;\"> by %s</h4>", t.Artist)
 	duration := fmt.Sprintf("%s<br>", formatDuration(t.Duration))
-	image := fmt.Sprintf("<img style=\"float: left; padding:0px;\"src=\"data:%s;base64,%s\"/><br>", t.Thumbnail.MimeType, string(t.Thumbnail.Data))
+	if t.Live {
+		duration = "LIVE<br>"
+	}
+	image := ""
+	if t.Thumbnail != nil {
+		image = fmt.Sprintf("<img style=\"float: left; padding:0px;\"src=\"data:%s;base64,%s\"/><br>", t.Thumbnail.MimeType, string(t.Thumbnail.Data))
+	}
 	return fmt.Sprintf("%s%s%s%s", title, artist, duration, image)
 }
 
-// Receives a youtube video and returns a track struct
-func YoutubeVideoToTrack(gc *gumble.Client, yc *youtube.Client, video *youtube.Video) (*Track, error) {
-	form, err := findBestFormat(video.Formats)
-	if err != nil {
-		return nil, err
+// Resolves the stream URL of the track if it is not resolved yet
+func (t *Track) resolve() error {
+	t.urlMutex.Lock()
+	defer t.urlMutex.Unlock()
+
+	if t.StreamURL != "" {
+		return nil
 	}
 
-	url, err := yc.GetStreamURL(video, form)
+	url, err := t.resolveURL()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var thumbnail *Thumbnail
-	if len(video.Thumbnails) > 0 {
-		thumbnail, err = NewThumbnail(video.Thumbnails[0].URL)
+	t.StreamURL = url
+	return nil
+}
+
+// Clears the stream URL so that it is resolved again
+func (t *Track) expire() {
+	t.urlMutex.Lock()
+	defer t.urlMutex.Unlock()
+
+	t.StreamURL = ""
+}
+
+// Creates a new stream for the track that starts from its offset.
+// The stream URL must be resolved.
+func (t *Track) newStream(c *gumble.Client) (*gumbleffmpeg.Stream, error) {
+	t.urlMutex.Lock()
+	defer t.urlMutex.Unlock()
+
+	source := gumbleffmpeg.SourceFile(t.StreamURL)
+	if t.openSource != nil {
+		t.setStreamTitle("")
+		var err error
+		source, err = t.openSource(t.StreamURL, t.setStreamTitle)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &Track{
-		Title:     video.Title,
-		Artist:    video.Author,
-		Duration:  video.Duration,
-		StreamURL: url,
-		PublicURL: fmt.Sprintf("https://www.youtube.com/watch?v=%s", video.ID),
-		Thumbnail: thumbnail,
-		Stream:    gumbleffmpeg.New(gc, gumbleffmpeg.SourceFile(url)),
-	}, nil
+	t.Stream = gumbleffmpeg.New(c, source)
+	t.Stream.Offset = t.Offset
+	return t.Stream, nil
 }
 
-// Returns a slice of tracks from a playlist
-func YoutubePlaylistToTracks(gc *gumble.Client, yc *youtube.Client, p *youtube.Playlist) ([]*Track, error) {
-	if len(p.Videos) == 0 {
-		return nil, ErrEmptyPlaylist
-	}
-
-	tracks := make([]*Track, 0)
-	errChan := make(chan error)
-	trackChan := make(chan *Track)
+// Returns the title of the song that a live stream is playing
+// or an empty string if it is unknown
+func (t *Track) StreamTitle() string {
+	t.titleMutex.Lock()
+	defer t.titleMutex.Unlock()
 
-	// Get track concurrently
-	for _, entry := range p.Videos {
-		go func(e *youtube.PlaylistEntry) {
-			video, err := yc.VideoFromPlaylistEntry(e)
-			if err != nil {
-				errChan <- err
-				return
-			}
-
-			track, err := YoutubeVideoToTrack(gc, yc, video)
-			if err != nil {
-				errChan <- err
-				return
-			}
-
-			trackChan <- track
-		}(entry)
+	return t.streamTitle
+}
 
-	}
+// Sets the title of the song that a live stream is playing
+func (t *Track) setStreamTitle(title string) {
+	t.titleMutex.Lock()
+	defer t.titleMutex.Unlock()
 
-	counter := len(p.Videos)
-	for counter > 0 {
-		select {
-		case track := <-trackChan:
-			tracks = append(tracks, track)
-			counter--
-		case err := <-errChan:
-			return nil, err
-		}
-	}
-
-	return tracks, nil
+	t.streamTitle = title
 }
 
-// Finds the best audio formats for a format list
-// and returns an error if no format is found
-func findBestFormat(formats youtube.FormatList) (*youtube.Format, error) {
-	f := formats.FindByItag(251)
-	if f != nil {
-		return f, nil
-	}
-
-	f = formats.FindByItag(250)
-	if f != nil {
-		return f, nil
+// Stops the stream of the track if there is one
+func (t *Track) stopStream() {
+	if t.Stream != nil {
+		t.Stream.Stop()
 	}
+}
 
-	f = formats.FindByItag(249)
-	if f != nil {
-		return f, nil
+// Returns the position of the stream in the track
+func (t *Track) position() time.Duration {
+	if t.Stream == nil {
+		return t.Offset
 	}
 
-	return nil, ErrNoFormat
+	return t.Stream.Offset + t.Stream.Elapsed()
 }
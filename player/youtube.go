@@ -0,0 +1,294 @@
+package player
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// The number of videos of a playlist that are fetched
+// at the same time if it is not configured
+const DefaultImportWorkers = 4
+
+// How long a request to youtube can take
+const youtubeRequestTimeout = 30 * time.Second
+
+// The source for youtube videos and playlists
+type YoutubeSource struct {
+	client *youtube.Client
+	// The number of videos of a playlist that are fetched at the same time
+	workers int
+}
+
+// Creates and returns a youtube source that fetches the
+// videos of playlists with the number of workers
+func NewYoutubeSource(workers int) *YoutubeSource {
+	if workers <= 0 {
+		workers = DefaultImportWorkers
+	}
+
+	client := &youtube.Client{HTTPClient: &http.Client{Timeout: youtubeRequestTimeout}}
+	return &YoutubeSource{client: client, workers: workers}
+}
+
+// Returns whether the URL is a youtube URL of a video or a playlist.
+// The URL is parsed without any requests.
+func (s *YoutubeSource) CanHandle(u *url.URL) bool {
+	_, ok := parseYoutubeURL(u)
+	return ok
+}
+
+// Receives a youtube video or playlist URL and returns its tracks or
+// an error. Only the video is returned from a URL that has both.
+func (s *YoutubeSource) Tracks(u *url.URL) ([]*Track, error) {
+	return s.Import(u, ImportOptions{Mode: PlaylistVideo})
+}
+
+// Receives a youtube video or playlist URL and returns its tracks or an
+// error. The mode of the options decides the tracks of a URL that has both
+// a video and a playlist. If the playlist cannot be read only the video is
+// returned, since generated playlists like mixes cannot be read.
+func (s *YoutubeSource) Import(u *url.URL, opts ImportOptions) ([]*Track, error) {
+	yu, ok := parseYoutubeURL(u)
+	if !ok {
+		return nil, ErrIncorrectURL
+	}
+
+	if yu.VideoID == "" {
+		return s.playlistTracks(yu.PlaylistID, "", opts)
+	}
+
+	if yu.PlaylistID != "" && opts.Mode != PlaylistVideo {
+		startID := ""
+		if opts.Mode == PlaylistFromVideo {
+			startID = yu.VideoID
+		}
+
+		tracks, err := s.playlistTracks(yu.PlaylistID, startID, opts)
+		if err == nil || isPartial(tracks, err) {
+			return tracks, err
+		}
+		log.Printf("Could not read the playlist %s, adding only the video: %v\n", yu.PlaylistID, err)
+	}
+
+	track, err := s.videoTrack(yu)
+	if err != nil {
+		return nil, err
+	}
+	return []*Track{track}, nil
+}
+
+// Returns the track of the video of the URL
+func (s *YoutubeSource) videoTrack(yu *youtubeURL) (*Track, error) {
+	video, err := s.client.GetVideo(yu.VideoID)
+	if err != nil {
+		return nil, err
+	}
+
+	track, err := YoutubeVideoToTrack(s.client, video)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the timestamp of the URL if there is one
+	if yu.Start < track.Duration {
+		track.Offset = yu.Start
+	}
+
+	return track, nil
+}
+
+// Returns the tracks of the playlist with the ID. If startID is not
+// empty the tracks start from the video with that ID, or from the
+// start of the playlist if the video is not in it. The videos after
+// the limit of the options are skipped without being fetched.
+func (s *YoutubeSource) playlistTracks(id, startID string, opts ImportOptions) ([]*Track, error) {
+	playlist, err := s.client.GetPlaylist(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if startID != "" {
+		for i, entry := range playlist.Videos {
+			if entry.ID == startID {
+				playlist.Videos = playlist.Videos[i:]
+				break
+			}
+		}
+	}
+
+	var dropped []SkippedVideo
+	if opts.Limit > 0 && len(playlist.Videos) > opts.Limit {
+		for _, entry := range playlist.Videos[opts.Limit:] {
+			dropped = append(dropped, SkippedVideo{Title: entry.Title, Err: ErrQueueFull})
+		}
+		playlist.Videos = playlist.Videos[:opts.Limit]
+	}
+
+	tracks, err := YoutubePlaylistToTracks(s.client, playlist, s.workers, opts.Progress)
+	return tracks, withSkipped(err, dropped)
+}
+
+// Parses a youtube timestamp like "90", "90s" or "1m30s".
+// Returns false if the timestamp is empty or invalid.
+func parseTimestamp(t string) (time.Duration, bool) {
+	if t == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(t); err == nil {
+		return time.Duration(seconds) * time.Second, seconds > 0
+	}
+
+	d, err := time.ParseDuration(t)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+// Receives a youtube video and returns a track struct.
+// The stream URL of the track is resolved when it is played.
+func YoutubeVideoToTrack(yc *youtube.Client, video *youtube.Video) (*Track, error) {
+	// Fail early if the video cannot be played
+	if _, err := findBestFormat(video.Formats); err != nil {
+		return nil, err
+	}
+
+	var thumbnail *Thumbnail
+	var err error
+	if len(video.Thumbnails) > 0 {
+		thumbnail, err = NewThumbnail(video.Thumbnails[0].URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Track{
+		Title:      video.Title,
+		Artist:     video.Author,
+		Duration:   video.Duration,
+		PublicURL:  fmt.Sprintf("https://www.youtube.com/watch?v=%s", video.ID),
+		Thumbnail:  thumbnail,
+		resolveURL: youtubeStreamURL(yc, video.ID),
+	}, nil
+}
+
+// Returns a function that fetches the video with the ID
+// and returns the stream URL of its best audio format
+func youtubeStreamURL(yc *youtube.Client, id string) func() (string, error) {
+	return func() (string, error) {
+		video, err := yc.GetVideo(id)
+		if err != nil {
+			return "", err
+		}
+
+		form, err := findBestFormat(video.Formats)
+		if err != nil {
+			return "", err
+		}
+
+		return yc.GetStreamURL(video, form)
+	}
+}
+
+// Returns a slice of tracks from a playlist in the order of the playlist.
+// The videos are fetched by the number of workers, at least one, at the same time.
+// Videos that cannot be added are skipped and reported with a
+// SkippedError, which is returned together with the rest of the tracks.
+// If progress is not nil it is called before the first video and after
+// each video is fetched, from the goroutines of the workers.
+func YoutubePlaylistToTracks(yc *youtube.Client, p *youtube.Playlist, workers int, progress func(done, total int)) ([]*Track, error) {
+	if len(p.Videos) == 0 {
+		return nil, ErrEmptyPlaylist
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var done int32
+	report := func(n int32) {
+		if progress != nil {
+			progress(int(n), len(p.Videos))
+		}
+	}
+	report(0)
+
+	// Each worker writes only the indexes it receives
+	// so the results keep the order of the playlist
+	results := make([]*Track, len(p.Videos))
+	errs := make([]error, len(p.Videos))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(p.Videos); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = youtubeEntryToTrack(yc, p.Videos[i])
+				report(atomic.AddInt32(&done, 1))
+			}
+		}()
+	}
+
+	for i := range p.Videos {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	tracks := make([]*Track, 0, len(p.Videos))
+	var skipped []SkippedVideo
+	for i, track := range results {
+		if errs[i] != nil {
+			skipped = append(skipped, SkippedVideo{Title: p.Videos[i].Title, Err: errs[i]})
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+
+	if len(skipped) > 0 {
+		return tracks, &SkippedError{Skipped: skipped}
+	}
+	return tracks, nil
+}
+
+// Fetches the video of the playlist entry and returns its track
+func youtubeEntryToTrack(yc *youtube.Client, e *youtube.PlaylistEntry) (*Track, error) {
+	video, err := yc.VideoFromPlaylistEntry(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return YoutubeVideoToTrack(yc, video)
+}
+
+// Finds the best audio formats for a format list
+// and returns an error if no format is found
+func findBestFormat(formats youtube.FormatList) (*youtube.Format, error) {
+	f := formats.FindByItag(251)
+	if f != nil {
+		return f, nil
+	}
+
+	f = formats.FindByItag(250)
+	if f != nil {
+		return f, nil
+	}
+
+	f = formats.FindByItag(249)
+	if f != nil {
+		return f, nil
+	}
+
+	return nil, ErrNoFormat
+}
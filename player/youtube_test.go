@@ -0,0 +1,34 @@
+package player
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// A transport that fails every request so that no test reaches YouTube
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("offline")
+}
+
+func TestPlaylistToTracksWithoutWorkers(t *testing.T) {
+	yc := &youtube.Client{HTTPClient: &http.Client{Transport: failingTransport{}}}
+	playlist := &youtube.Playlist{Videos: []*youtube.PlaylistEntry{
+		{ID: "first", Title: "first"},
+		{ID: "second", Title: "second"},
+	}}
+
+	// The videos are still fetched by one worker
+	returnsInTime(t, "YoutubePlaylistToTracks", func() error {
+		tracks, err := YoutubePlaylistToTracks(yc, playlist, 0, nil)
+		var skipped *SkippedError
+		if len(tracks) != 0 || !errors.As(err, &skipped) || len(skipped.Skipped) != 2 {
+			return errors.New("the failed videos were not skipped")
+		}
+		return nil
+	})
+}
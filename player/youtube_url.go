@@ -0,0 +1,91 @@
+package player
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	youtubeVideoIDRegex    = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	youtubePlaylistIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{2,}$`)
+)
+
+// The hosts of the youtube website, other than the short link host
+var youtubeHosts = map[string]bool{
+	"youtube.com":              true,
+	"www.youtube.com":          true,
+	"m.youtube.com":            true,
+	"music.youtube.com":        true,
+	"youtube-nocookie.com":     true,
+	"www.youtube-nocookie.com": true,
+}
+
+// The paths of the youtube website that are followed by a video ID
+var youtubeVideoPaths = []string{"/shorts/", "/embed/", "/live/", "/v/"}
+
+// The video and the playlist that a youtube URL points to.
+// Either of them can be empty but not both.
+type youtubeURL struct {
+	VideoID    string
+	PlaylistID string
+	// The position the video starts from
+	Start time.Duration
+}
+
+// Reads the video ID, the playlist ID and the start position of a
+// youtube URL without any requests. Returns false if the URL is not
+// a youtube URL or does not point to a video or a playlist.
+func parseYoutubeURL(u *url.URL) (*youtubeURL, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	query := u.Query()
+	res := &youtubeURL{PlaylistID: query.Get("list")}
+
+	switch {
+	case host == "youtu.be":
+		res.VideoID = strings.Trim(u.Path, "/")
+	case !youtubeHosts[host]:
+		return nil, false
+	case u.Path == "/watch":
+		res.VideoID = query.Get("v")
+	case u.Path == "/playlist":
+	default:
+		for _, prefix := range youtubeVideoPaths {
+			if strings.HasPrefix(u.Path, prefix) {
+				res.VideoID = strings.Trim(strings.TrimPrefix(u.Path, prefix), "/")
+				break
+			}
+		}
+
+		// Embedded playlists have this path instead of a video ID
+		if res.VideoID == "videoseries" {
+			res.VideoID = ""
+		}
+	}
+
+	if res.VideoID != "" && !youtubeVideoIDRegex.MatchString(res.VideoID) {
+		return nil, false
+	}
+
+	if res.PlaylistID != "" && !youtubePlaylistIDRegex.MatchString(res.PlaylistID) {
+		res.PlaylistID = ""
+	}
+
+	if res.VideoID == "" && res.PlaylistID == "" {
+		return nil, false
+	}
+
+	// Embedded videos use start instead of t
+	if start, ok := parseTimestamp(query.Get("t")); ok {
+		res.Start = start
+	} else if start, ok := parseTimestamp(query.Get("start")); ok {
+		res.Start = start
+	}
+
+	return res, true
+}
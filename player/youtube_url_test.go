@@ -0,0 +1,77 @@
+package player
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseYoutubeURL(t *testing.T) {
+	const (
+		video    = "dQw4w9WgXcQ"
+		playlist = "PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI"
+	)
+
+	tests := []struct {
+		name string
+		url  string
+		// Nil if the URL is not a youtube URL
+		want *youtubeURL
+	}{
+		{"watch", "https://www.youtube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"short link", "https://youtu.be/" + video, &youtubeURL{VideoID: video}},
+		{"short link with playlist", "https://youtu.be/" + video + "?list=" + playlist, &youtubeURL{VideoID: video, PlaylistID: playlist}},
+		{"without www", "https://youtube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"mobile", "https://m.youtube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"music", "https://music.youtube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"upper case host", "https://WWW.YouTube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"http", "http://www.youtube.com/watch?v=" + video, &youtubeURL{VideoID: video}},
+		{"shorts", "https://www.youtube.com/shorts/" + video, &youtubeURL{VideoID: video}},
+		{"embed", "https://www.youtube.com/embed/" + video, &youtubeURL{VideoID: video}},
+		{"embed without cookies", "https://www.youtube-nocookie.com/embed/" + video, &youtubeURL{VideoID: video}},
+		{"embedded playlist", "https://www.youtube.com/embed/videoseries?list=" + playlist, &youtubeURL{PlaylistID: playlist}},
+		{"live", "https://www.youtube.com/live/" + video, &youtubeURL{VideoID: video}},
+		{"old embed", "https://www.youtube.com/v/" + video, &youtubeURL{VideoID: video}},
+		{"playlist", "https://www.youtube.com/playlist?list=" + playlist, &youtubeURL{PlaylistID: playlist}},
+		{"watch with playlist", "https://www.youtube.com/watch?v=" + video + "&list=" + playlist + "&index=3", &youtubeURL{VideoID: video, PlaylistID: playlist}},
+		{"seconds", "https://youtu.be/" + video + "?t=90", &youtubeURL{VideoID: video, Start: 90 * time.Second}},
+		{"duration", "https://www.youtube.com/watch?v=" + video + "&t=1m30s", &youtubeURL{VideoID: video, Start: 90 * time.Second}},
+		{"embed start", "https://www.youtube.com/embed/" + video + "?start=42", &youtubeURL{VideoID: video, Start: 42 * time.Second}},
+		{"t before start", "https://www.youtube.com/embed/" + video + "?t=10&start=42", &youtubeURL{VideoID: video, Start: 10 * time.Second}},
+		{"invalid start", "https://youtu.be/" + video + "?t=soon", &youtubeURL{VideoID: video}},
+		{"invalid playlist", "https://www.youtube.com/watch?v=" + video + "&list=a!b", &youtubeURL{VideoID: video}},
+
+		{"invalid video", "https://www.youtube.com/watch?v=short", nil},
+		{"invalid short link", "https://youtu.be/" + video + "extra", nil},
+		{"playlist without ID", "https://www.youtube.com/playlist", nil},
+		{"channel", "https://www.youtube.com/@channel", nil},
+		{"home page", "https://www.youtube.com/", nil},
+		{"other host", "https://example.com/watch?v=" + video, nil},
+		{"look alike host", "https://youtube.com.example.com/watch?v=" + video, nil},
+		{"other scheme", "ftp://www.youtube.com/watch?v=" + video, nil},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		got, ok := parseYoutubeURL(u)
+		if test.want == nil {
+			if ok {
+				t.Errorf("%s: got %+v, want no match", test.name, got)
+			}
+			continue
+		}
+
+		if !ok {
+			t.Errorf("%s: got no match, want %+v", test.name, test.want)
+			continue
+		}
+
+		if *got != *test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
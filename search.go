@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/evris99/mumble-jackson/youtube_search"
+)
+
+// How long the results of a search can be picked from
+const searchResultsTimeout = 5 * time.Minute
+
+// The backends that youtube can be searched with
+const (
+	SearchAuto = "auto"
+	SearchAPI  = "api"
+	SearchWeb  = "web"
+)
+
+var (
+	ErrNoSearchResults = errors.New("no search results to pick from")
+	ErrSearchBackend   = errors.New("the search backend must be auto, api or web")
+)
+
+// Returns the searcher of the backend of the config. The auto
+// backend uses the Data API if there is a key and the web page otherwise.
+func getSearcher(c *Config) (youtube_search.Searcher, error) {
+	switch c.SearchBackend {
+	case SearchAPI:
+		if c.YoutubeAPIKey == "" {
+			return nil, ErrNoYoutubeAPIKey
+		}
+		return youtube_search.APISearcher(c.YoutubeAPIKey), nil
+	case SearchWeb:
+		return youtube_search.WebSearch, nil
+	case SearchAuto:
+		if c.YoutubeAPIKey != "" {
+			return youtube_search.APISearcher(c.YoutubeAPIKey), nil
+		}
+		return youtube_search.WebSearch, nil
+	default:
+		return nil, ErrSearchBackend
+	}
+}
+
+// The search results of a user that are waiting for one to be picked
+type pendingResults struct {
+	results []youtube_search.Result
+	expires time.Time
+}
+
+// The latest search results of each user. Each user picks
+// from their own results so that searches do not mix.
+type pendingSearches struct {
+	mutex   sync.Mutex
+	results map[string]pendingResults
+}
+
+// Creates and returns an empty list of pending searches
+func newPendingSearches() *pendingSearches {
+	return &pendingSearches{results: make(map[string]pendingResults)}
+}
+
+// Saves the results of the user, replacing their previous ones.
+// The expired results of all users are removed.
+func (s *pendingSearches) set(user string, results []youtube_search.Result) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for name, pending := range s.results {
+		if now.After(pending.expires) {
+			delete(s.results, name)
+		}
+	}
+
+	s.results[user] = pendingResults{
+		results: results,
+		expires: now.Add(searchResultsTimeout),
+	}
+}
+
+// Returns the result of the user at position n. The positions
+// start from 1 as in the list of results. The results are kept
+// until they expire so that more than one can be picked.
+func (s *pendingSearches) pick(user string, n int) (youtube_search.Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending, ok := s.results[user]
+	if !ok || time.Now().After(pending.expires) {
+		delete(s.results, user)
+		return youtube_search.Result{}, ErrNoSearchResults
+	}
+
+	if n < 1 || n > len(pending.results) {
+		return youtube_search.Result{}, ErrInvalidNumber
+	}
+
+	return pending.results[n-1], nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evris99/mumble-jackson/youtube_search"
+)
+
+func TestFormatSearchResults(t *testing.T) {
+	results := []youtube_search.Result{
+		{Title: "Rock & Roll <Live>", Channel: "<b>Band</b>", Duration: 3*time.Minute + 5*time.Second},
+		{Title: "Stream", Channel: "Radio"},
+	}
+
+	got := formatSearchResults(results, "!")
+	for _, want := range []string{
+		"<b>1: Rock &amp; Roll &lt;Live&gt;</b> by &lt;b&gt;Band&lt;/b&gt;",
+		"<b>2: Stream</b> by Radio (?)",
+		"Add one with !pick $NUM",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q does not contain %q", got, want)
+		}
+	}
+
+	if strings.Contains(got, "<Live>") || strings.Contains(got, "<b>Band") {
+		t.Errorf("%q contains unescaped markup", got)
+	}
+}
+
+func TestPendingSearches(t *testing.T) {
+	searches := newPendingSearches()
+	results := []youtube_search.Result{{VideoID: "a"}, {VideoID: "b"}}
+	searches.set("alice", results)
+
+	if result, err := searches.pick("alice", 2); err != nil || result.VideoID != "b" {
+		t.Fatalf("got %+v and %v, want the second result", result, err)
+	}
+
+	// The results are kept so that more than one can be picked
+	if result, err := searches.pick("alice", 1); err != nil || result.VideoID != "a" {
+		t.Fatalf("got %+v and %v, want the first result", result, err)
+	}
+
+	if _, err := searches.pick("alice", 3); err != ErrInvalidNumber {
+		t.Errorf("got error %v, want %v", err, ErrInvalidNumber)
+	}
+
+	if _, err := searches.pick("bob", 1); err != ErrNoSearchResults {
+		t.Errorf("got error %v, want %v", err, ErrNoSearchResults)
+	}
+
+	// Expired results cannot be picked
+	searches.results["alice"] = pendingResults{results: results, expires: time.Now().Add(-time.Second)}
+	if _, err := searches.pick("alice", 1); err != ErrNoSearchResults {
+		t.Errorf("got error %v for expired results, want %v", err, ErrNoSearchResults)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -14,50 +19,115 @@ var (
 	ErrEmptyResponse error = errors.New("the search result is empty")
 )
 
+// Searches youtube and returns the first max results
+type Searcher func(query string, max int) ([]Result, error)
+
+// Returns a searcher that uses the Data API with the key
+func APISearcher(apiKey string) Searcher {
+	return func(query string, max int) ([]Result, error) {
+		return SearchResults(query, apiKey, max)
+	}
+}
+
+// Matches ISO 8601 durations like "PT1H2M3S" as used by the API
+var durationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
 type ID struct {
 	VideoID string `json:"videoId"`
 }
 
+type Snippet struct {
+	Title        string `json:"title"`
+	ChannelTitle string `json:"channelTitle"`
+}
+
 type Item struct {
-	ItemID ID `json:"id"`
+	ItemID  ID      `json:"id"`
+	Snippet Snippet `json:"snippet"`
 }
 
 type SearchResponse struct {
 	Items []Item `json:"items"`
 }
 
+type ContentDetails struct {
+	Duration string `json:"duration"`
+}
+
+type VideoItem struct {
+	ID             string         `json:"id"`
+	ContentDetails ContentDetails `json:"contentDetails"`
+}
+
+type VideosResponse struct {
+	Items []VideoItem `json:"items"`
+}
+
+// A video found by a search
+type Result struct {
+	VideoID string
+	Title   string
+	Channel string
+	// The duration of the video or zero if it is unknown
+	Duration time.Duration
+}
+
+// Returns the URL of the video of the result
+func (r Result) URL() string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s", r.VideoID)
+}
+
 // Returns the URL of the first search result from Youtube based on the query
 func Search(query, apiKey string) (string, error) {
-	url, err := getApiURL(query, apiKey)
+	results, err := SearchResults(query, apiKey, 1)
 	if err != nil {
 		return "", err
 	}
 
+	return results[0].URL(), nil
+}
+
+// Returns the first max search results from Youtube based on the query.
+// The durations are fetched with a second request and if it fails
+// the results are returned without them.
+func SearchResults(query, apiKey string, max int) ([]Result, error) {
+	url, err := getApiURL(query, apiKey, max)
+	if err != nil {
+		return nil, err
+	}
+
 	resp, err := http.Get(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", ErrRequest
+		return nil, ErrRequest
 	}
 
-	id, err := extractID(resp.Body)
+	results, err := extractResults(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if durations, err := getDurations(results, apiKey); err == nil {
+		for i := range results {
+			results[i].Duration = durations[results[i].VideoID]
+		}
 	}
 
-	return fmt.Sprintf("https://www.youtube.com/watch?v=%s", id), nil
+	return results, nil
 }
 
 // Returns the url for making the search request based on the query and the API key
-func getApiURL(query, apiKey string) (string, error) {
-	queryParams := make(url.Values, 4)
-	queryParams.Add("part", "id")
+func getApiURL(query, apiKey string, max int) (string, error) {
+	queryParams := make(url.Values, 5)
+	queryParams.Add("part", "snippet")
 	queryParams.Add("q", query)
 	queryParams.Add("key", apiKey)
 	queryParams.Add("type", "video")
+	queryParams.Add("maxResults", strconv.Itoa(max))
 
 	u, err := url.Parse("https://www.googleapis.com/youtube/v3/search")
 	if err != nil {
@@ -68,18 +138,82 @@ func getApiURL(query, apiKey string) (string, error) {
 	return u.String(), nil
 }
 
-// Extracts and returns the video ID from the http response
-func extractID(body io.ReadCloser) (string, error) {
+// Extracts and returns the search results from the http response
+func extractResults(body io.ReadCloser) ([]Result, error) {
 	decoder := json.NewDecoder(body)
 	searchRes := new(SearchResponse)
 	err := decoder.Decode(searchRes)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(searchRes.Items) == 0 {
-		return "", ErrEmptyResponse
+		return nil, ErrEmptyResponse
+	}
+
+	// The API escapes the titles for HTML
+	results := make([]Result, len(searchRes.Items))
+	for i, item := range searchRes.Items {
+		results[i] = Result{
+			VideoID: item.ItemID.VideoID,
+			Title:   html.UnescapeString(item.Snippet.Title),
+			Channel: html.UnescapeString(item.Snippet.ChannelTitle),
+		}
+	}
+
+	return results, nil
+}
+
+// Returns the durations of the videos of the results by their ID
+func getDurations(results []Result, apiKey string) (map[string]time.Duration, error) {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.VideoID
+	}
+
+	queryParams := make(url.Values, 3)
+	queryParams.Add("part", "contentDetails")
+	queryParams.Add("id", strings.Join(ids, ","))
+	queryParams.Add("key", apiKey)
+
+	resp, err := http.Get("https://www.googleapis.com/youtube/v3/videos?" + queryParams.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrRequest
+	}
+
+	videosRes := new(VideosResponse)
+	if err := json.NewDecoder(resp.Body).Decode(videosRes); err != nil {
+		return nil, err
+	}
+
+	durations := make(map[string]time.Duration, len(videosRes.Items))
+	for _, item := range videosRes.Items {
+		durations[item.ID] = parseISODuration(item.ContentDetails.Duration)
+	}
+
+	return durations, nil
+}
+
+// Parses an ISO 8601 duration like "PT4M13S".
+// Returns zero if the duration is invalid.
+func parseISODuration(s string) time.Duration {
+	matches := durationRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return 0
+	}
+
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if n, err := strconv.Atoi(matches[i+1]); err == nil {
+			d += time.Duration(n) * unit
+		}
 	}
 
-	return searchRes.Items[0].ItemID.VideoID, nil
+	return d
 }
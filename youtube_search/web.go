@@ -0,0 +1,181 @@
+package youtube_search
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The public page of the search results of youtube
+const webSearchURL = "https://www.youtube.com/results"
+
+// The maximum size of a results page that is read
+const maxPageSize = 5 * 1024 * 1024
+
+// The prefixes of the script that holds the data of a results page
+var initialDataPrefixes = [][]byte{
+	[]byte("var ytInitialData = "),
+	[]byte(`window["ytInitialData"] = `),
+}
+
+// A text of a results page. It is either a simple text or made of runs.
+type pageText struct {
+	SimpleText string `json:"simpleText"`
+	Runs       []struct {
+		Text string `json:"text"`
+	} `json:"runs"`
+}
+
+// Returns the whole text
+func (t pageText) String() string {
+	if t.SimpleText != "" {
+		return t.SimpleText
+	}
+
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+// A video of a results page
+type videoRenderer struct {
+	VideoID    string   `json:"videoId"`
+	Title      pageText `json:"title"`
+	OwnerText  pageText `json:"ownerText"`
+	LengthText pageText `json:"lengthText"`
+}
+
+// The parts of the data of a results page that are read
+type resultsPage struct {
+	Contents struct {
+		TwoColumnSearchResultsRenderer struct {
+			PrimaryContents struct {
+				SectionListRenderer struct {
+					Contents []struct {
+						ItemSectionRenderer struct {
+							Contents []struct {
+								VideoRenderer *videoRenderer `json:"videoRenderer"`
+							} `json:"contents"`
+						} `json:"itemSectionRenderer"`
+					} `json:"contents"`
+				} `json:"sectionListRenderer"`
+			} `json:"primaryContents"`
+		} `json:"twoColumnSearchResultsRenderer"`
+	} `json:"contents"`
+}
+
+// Returns the first max search results from the public results page
+// of youtube based on the query. It does not need an API key.
+func WebSearch(query string, max int) ([]Result, error) {
+	req, err := http.NewRequest(http.MethodGet, webSearchURL+"?"+url.Values{"search_query": {query}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The page is localized so a language is set for consistent results
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrRequest
+	}
+
+	return parseResultsPage(io.LimitReader(resp.Body, maxPageSize), max)
+}
+
+// Extracts the first max videos from a results page of youtube
+func parseResultsPage(r io.Reader, max int) ([]Result, error) {
+	page, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := extractInitialData(page)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(resultsPage)
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, max)
+	sections := res.Contents.TwoColumnSearchResultsRenderer.PrimaryContents.SectionListRenderer.Contents
+	for _, section := range sections {
+		// Other items like channels, playlists and ads are skipped
+		for _, item := range section.ItemSectionRenderer.Contents {
+			video := item.VideoRenderer
+			if video == nil || video.VideoID == "" {
+				continue
+			}
+
+			if len(results) == max {
+				return results, nil
+			}
+
+			results = append(results, Result{
+				VideoID:  video.VideoID,
+				Title:    video.Title.String(),
+				Channel:  video.OwnerText.String(),
+				Duration: parseClockDuration(video.LengthText.String()),
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	return results, nil
+}
+
+// Returns the JSON data that the script of a results page assigns
+func extractInitialData(page []byte) ([]byte, error) {
+	for _, prefix := range initialDataPrefixes {
+		start := bytes.Index(page, prefix)
+		if start < 0 {
+			continue
+		}
+
+		data := page[start+len(prefix):]
+		end := bytes.Index(data, []byte(";</script>"))
+		if end < 0 {
+			return nil, ErrRequest
+		}
+
+		return data[:end], nil
+	}
+
+	return nil, ErrRequest
+}
+
+// Parses a duration of the form "H:MM:SS" or "M:SS".
+// Returns zero if the duration is invalid, like for live videos.
+func parseClockDuration(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+
+	var seconds int
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second
+}
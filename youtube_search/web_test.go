@@ -0,0 +1,101 @@
+package youtube_search
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Parses the results page of the test data
+func parseTestPage(t *testing.T, name string, max int) ([]Result, error) {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	return parseResultsPage(f, max)
+}
+
+func TestParseResultsPage(t *testing.T) {
+	want := []Result{
+		{VideoID: "dQw4w9WgXcQ", Title: "Rick Astley - Never Gonna Give You Up", Channel: "Rick Astley", Duration: 3*time.Minute + 33*time.Second},
+		{VideoID: "live0000001", Title: "Lofi radio & beats <24/7>", Channel: "Lofi Girl"},
+		{VideoID: "long0000001", Title: "A long mix", Channel: "DJ Test", Duration: time.Hour + 2*time.Minute + 3*time.Second},
+		{VideoID: "next0000001", Title: "On the next section", Channel: "Someone", Duration: 59 * time.Second},
+	}
+
+	// Both pages have the same data assigned in different ways
+	for _, name := range []string{"results.html", "results_window.html"} {
+		results, err := parseTestPage(t, name, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Ads, channels and shelves are skipped
+		if len(results) != len(want) {
+			t.Fatalf("%s: got %d results, want %d: %+v", name, len(results), len(want), results)
+		}
+
+		for i, result := range results {
+			if result != want[i] {
+				t.Errorf("%s: result %d: got %+v, want %+v", name, i, result, want[i])
+			}
+		}
+	}
+}
+
+func TestParseResultsPageMax(t *testing.T) {
+	for _, max := range []int{1, 2, 4} {
+		results, err := parseTestPage(t, "results.html", max)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != max {
+			t.Errorf("got %d results, want %d", len(results), max)
+		}
+	}
+}
+
+func TestParseResultsPageErrors(t *testing.T) {
+	tests := map[string]error{
+		"<html><body>No data</body></html>":                       ErrRequest,
+		"<script>var ytInitialData = {\"contents\":{}}":           ErrRequest,
+		"<script>var ytInitialData = {\"contents\":{}};</script>": ErrEmptyResponse,
+	}
+
+	for page, want := range tests {
+		if _, err := parseResultsPage(strings.NewReader(page), 5); err != want {
+			t.Errorf("%q: got error %v, want %v", page, err, want)
+		}
+	}
+
+	page := "<script>var ytInitialData = {\"contents\":{\"twoColumn\";</script>"
+	if _, err := parseResultsPage(strings.NewReader(page), 5); err == nil {
+		t.Errorf("%q: got no error for invalid data", page)
+	}
+}
+
+func TestParseClockDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"0:59":     59 * time.Second,
+		"3:33":     3*time.Minute + 33*time.Second,
+		"1:02:03":  time.Hour + 2*time.Minute + 3*time.Second,
+		"":         0,
+		"LIVE":     0,
+		"1:-2":     0,
+		"1::2":     0,
+		"12:34:56": 12*time.Hour + 34*time.Minute + 56*time.Second,
+	}
+
+	for s, want := range tests {
+		if got := parseClockDuration(s); got != want {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+}